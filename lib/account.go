@@ -0,0 +1,101 @@
+package lib
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+const minPasswordLength = 6
+
+func ResetPasswordCLI(c *cli.Context) error {
+	email := c.String("email")
+	if email == "" {
+		return fmt.Errorf("email is required")
+	}
+
+	err := newPlatformClient("").SendResetLink(context.Background(), email)
+	if err != nil {
+		return fmt.Errorf("failed to reset password: %w", err)
+	}
+
+	fmt.Println("Password reset link is sent to your email.")
+	return nil
+}
+
+func ChangePasswordCLI(c *cli.Context) error {
+	authCompleted, token := CheckAuthCompleted()
+	if !authCompleted {
+		return fmt.Errorf("authentication not completed")
+	}
+
+	oldPassword, err := promptPassword("Current password: ")
+	if err != nil {
+		return err
+	}
+	newPassword, err := promptPassword("New password: ")
+	if err != nil {
+		return err
+	}
+	againPassword, err := promptPassword("Repeat new password: ")
+	if err != nil {
+		return err
+	}
+
+	if newPassword != againPassword {
+		return fmt.Errorf("passwords do not match")
+	}
+	if len(newPassword) < minPasswordLength {
+		return fmt.Errorf("password must be at least %d characters long", minPasswordLength)
+	}
+
+	err = newPlatformClient(token).ChangePassword(context.Background(), oldPassword, newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to change password: %w", err)
+	}
+
+	fmt.Println("Password changed.")
+	return nil
+}
+
+func DeleteAccountCLI(c *cli.Context) error {
+	authCompleted, token := CheckAuthCompleted()
+	if !authCompleted {
+		return fmt.Errorf("authentication not completed")
+	}
+
+	pc := newPlatformClient(token)
+	info, err := pc.UserInfo(context.Background())
+	if err != nil {
+		return err
+	}
+
+	if !c.Bool("yes") {
+		fmt.Printf("This permanently deletes account %s with all course progress.\nType the account email to confirm: ", info.Email)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.TrimSpace(answer) != info.Email {
+			return fmt.Errorf("confirmation does not match, account is not deleted")
+		}
+	}
+
+	err = pc.DeleteAccount(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to delete account: %w", err)
+	}
+
+	store, err := NewTokenStore()
+	if err != nil {
+		return err
+	}
+	err = store.Delete()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Account deleted. Local credentials removed.")
+	return nil
+}
@@ -1,169 +1,212 @@
 package lib
 
 import (
-	"bytes"
-	"encoding/json"
+	"bufio"
+	"context"
 	"fmt"
-	"io/ioutil"
-	"net/http"
+	"io"
 	"os"
-	"path/filepath"
+	"strings"
 	"syscall"
 
 	"github.com/urfave/cli/v2"
+	"gl.biggo.pro/devopstrain/kurator/lib/client"
 	"golang.org/x/crypto/ssh/terminal"
 )
 
-type LoginResponse struct {
-	Token string `json:"token"`
-}
+const (
+	tokenEnvVar    = "KURATOR_TOKEN"
+	passwordEnvVar = "KURATOR_PASSWORD"
+)
+
+type UserInfo = client.UserInfo
 
 func LoginUserCLI(c *cli.Context) error {
 	email := c.String("email")
-	password := getPassword()
+
+	if c.String("api-url") != "" {
+		targetURL = strings.TrimSuffix(c.String("api-url"), "/")
+	}
+
+	// A pre-issued token (e.g. CI secret) is only checked, it must not end up
+	// in the keyring or token file of the machine
+	if token := os.Getenv(tokenEnvVar); token != "" {
+		info, err := GetUserInfo(token)
+		if err != nil {
+			return fmt.Errorf("%s is not accepted by the platform: %w", tokenEnvVar, err)
+		}
+		fmt.Printf("Logged in as %s with %s, nothing is stored. Profile: %s\n", info.Email, tokenEnvVar, activeProfile)
+		return nil
+	}
 
 	if email == "" {
 		return fmt.Errorf("email is required")
 	}
 
-	token, err := LoginUser(email, password)
+	password, err := getPassword(c)
 	if err != nil {
 		return err
 	}
 
-	err = saveToken(token)
+	token, err := LoginUser(email, password)
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Login successful. Token: %s\n", token)
-	return nil
-}
-
-func SignUpUserCLI(c *cli.Context) error {
-	email := c.String("email")
-	name := c.String("name")
-
-	if email == "" {
-		return fmt.Errorf("email is required")
+	err = saveToken(token)
+	if err != nil {
+		return err
 	}
 
-	err := SignupUser(email, name)
+	err = updateActiveProfile(email, c.String("api-url"))
 	if err != nil {
 		return err
 	}
 
+	fmt.Printf("Login successful. Profile: %s\n", activeProfile)
 	return nil
 }
 
-func LoginUser(email, password string) (string, error) {
-	url := targetURL + "/auth_user"
+func LogoutUserCLI(c *cli.Context) error {
+	store, err := NewTokenStore()
+	if err != nil {
+		return err
+	}
 
-	payload := map[string]string{
-		"email":    email,
-		"password": password,
+	token, err := store.Get()
+	if err != nil {
+		fmt.Println("You are not logged in.")
+		return nil
 	}
-	jsonPayload, err := json.Marshal(payload)
+
+	err = LogoutUser(token)
 	if err != nil {
-		return "", err
+		fmt.Println("Failed to end session on the platform:", err)
 	}
 
-	req, err := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(jsonPayload))
+	err = store.Delete()
 	if err != nil {
-		return "", err
+		return err
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	fmt.Println("Logged out. Local credentials removed.")
+	return nil
+}
+
+func WhoAmICLI(c *cli.Context) error {
+	store, err := NewTokenStore()
 	if err != nil {
-		return "", err
+		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusAccepted {
-		return "", fmt.Errorf("server returned status: %s", resp.Status)
+	authCompleted, token := CheckAuthCompleted()
+	if !authCompleted {
+		return fmt.Errorf("authentication not completed")
 	}
 
-	body, err := ioutil.ReadAll(resp.Body)
+	info, err := GetUserInfo(token)
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	var loginResponse LoginResponse
-	err = json.Unmarshal(body, &loginResponse)
-	if err != nil {
-		return "", err
+	storage := store.Name()
+	if os.Getenv(tokenEnvVar) != "" {
+		storage = tokenEnvVar + " environment variable"
 	}
 
-	return loginResponse.Token, nil
+	fmt.Printf("Profile: %s\nAPI URL: %s\nName: %s\nEmail: %s\nToken storage: %s\n", activeProfile, targetURL, info.Name, info.Email, storage)
+	return nil
 }
 
-func SignupUser(email, name string) error {
-	url := targetURL + "/users"
+func SignUpUserCLI(c *cli.Context) error {
+	email := c.String("email")
+	name := c.String("name")
 
-	payload := struct {
-		Email string `json:"email"`
-		Name  string `json:"name"`
-	}{
-		Email: email,
-		Name:  name,
+	if email == "" {
+		return fmt.Errorf("email is required")
 	}
 
-	payloadBytes, err := json.Marshal(payload)
+	err := SignupUser(email, name)
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(payloadBytes))
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Content-Type", "application/json")
+	return nil
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+func LoginUser(email, password string) (string, error) {
+	loginResponse, err := client.New(targetURL, "").Login(context.Background(), email, password)
 	if err != nil {
-		return err
+		return "", err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusCreated {
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return err
-		}
-		return fmt.Errorf("failed to signup user. Status: %s. Response: %s", resp.Status, string(body))
+	return loginResponse.Token, nil
+}
+
+func SignupUser(email, name string) error {
+	err := client.New(targetURL, "").Signup(context.Background(), email, name)
+	if err != nil {
+		return fmt.Errorf("failed to signup user: %w", err)
 	}
 
 	fmt.Println("User signup successful. Your password is sent to your email.")
 	return nil
 }
 
+func LogoutUser(token string) error {
+	return client.New(targetURL, token).Logout(context.Background())
+}
+
+func GetUserInfo(token string) (UserInfo, error) {
+	return newPlatformClient(token).UserInfo(context.Background())
+}
+
 func saveToken(token string) error {
-	homeDir, err := os.UserHomeDir()
+	store, err := NewTokenStore()
 	if err != nil {
 		return err
 	}
 
-	configDir := filepath.Join(homeDir, ".config", "kurator")
-	err = os.MkdirAll(configDir, 0700)
-	if err != nil {
-		return err
+	return store.Set(token)
+}
+
+// getPassword reads the password from stdin when --password-stdin is set,
+// then from KURATOR_PASSWORD and finally prompts on the terminal.
+func getPassword(c *cli.Context) (string, error) {
+	if c.Bool("password-stdin") {
+		reader := bufio.NewReader(os.Stdin)
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		password := strings.TrimRight(line, "\r\n")
+		if password == "" {
+			return "", fmt.Errorf("empty password read from stdin")
+		}
+		return password, nil
 	}
 
-	filePath := filepath.Join(configDir, "token")
-	err = ioutil.WriteFile(filePath, []byte(token), 0600)
-	if err != nil {
-		return err
+	if password := os.Getenv(passwordEnvVar); password != "" {
+		return password, nil
 	}
 
-	return nil
+	if !terminal.IsTerminal(int(syscall.Stdin)) {
+		return "", fmt.Errorf("no terminal to read password from. Use --password-stdin, %s or %s", passwordEnvVar, tokenEnvVar)
+	}
+
+	return promptPassword("Enter password: ")
 }
 
-func getPassword() string {
-	fmt.Print("Enter password: ")
-	bytePassword, _ := terminal.ReadPassword(int(syscall.Stdin))
+func promptPassword(prompt string) (string, error) {
+	if !terminal.IsTerminal(int(syscall.Stdin)) {
+		return "", fmt.Errorf("no terminal to read password from")
+	}
+
+	fmt.Print(prompt)
+	bytePassword, err := terminal.ReadPassword(int(syscall.Stdin))
 	fmt.Println()
-	return string(bytePassword)
+	if err != nil {
+		return "", err
+	}
+	return string(bytePassword), nil
 }
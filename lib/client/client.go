@@ -0,0 +1,244 @@
+// Package client is a typed client for the Devopstrain platform API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultTimeout    = 30 * time.Second
+	DefaultRetries    = 2
+	DefaultRetryDelay = time.Second
+)
+
+type Client struct {
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+	// Retries is the number of extra attempts for GET, HEAD and OPTIONS
+	// requests that failed with a network error or a 502/503/504 status.
+	// PUT endpoints of the platform log in and change state, so they are
+	// sent once like POST.
+	Retries    int
+	RetryDelay time.Duration
+	// OnTokenRefresh is called with the new token after an expired one was refreshed.
+	OnTokenRefresh func(token string)
+}
+
+func New(baseURL, token string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+		Retries:    DefaultRetries,
+		RetryDelay: DefaultRetryDelay,
+	}
+}
+
+// Do sends a raw request to path on the platform. The request is authorized
+// with the client token unless the Token header is already set. On 401 the
+// token is refreshed once if the platform supports it.
+func (c *Client) Do(ctx context.Context, method, path string, header http.Header, body []byte) (*http.Response, error) {
+	resp, err := c.send(ctx, method, path, header, body, c.Token)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized || c.Token == "" || header.Get("Token") != "" {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	_, err = c.RefreshToken(ctx)
+	if err != nil {
+		return nil, ErrSessionExpired
+	}
+
+	return c.send(ctx, method, path, header, body, c.Token)
+}
+
+func (c *Client) send(ctx context.Context, method, path string, header http.Header, body []byte, token string) (*http.Response, error) {
+	attempts := 1
+	if isSafeMethod(method) {
+		attempts += c.Retries
+	}
+
+	var resp *http.Response
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(c.RetryDelay * time.Duration(attempt)):
+			}
+		}
+
+		var req *http.Request
+		req, err = http.NewRequestWithContext(ctx, method, c.BaseURL+path, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		for key, values := range header {
+			for _, value := range values {
+				req.Header.Add(key, value)
+			}
+		}
+		if body != nil && req.Header.Get("Content-Type") == "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if token != "" && req.Header.Get("Token") == "" {
+			req.Header.Set("Token", token)
+		}
+
+		resp, err = c.HTTPClient.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			continue
+		}
+		if !isRetryableStatus(resp.StatusCode) || attempt == attempts-1 {
+			return resp, nil
+		}
+		resp.Body.Close()
+	}
+
+	return resp, err
+}
+
+// call sends in as JSON, checks the status against expected and decodes the reply into out.
+func (c *Client) call(ctx context.Context, method, path string, in, out interface{}, expected ...int) error {
+	var body []byte
+	if in != nil {
+		var err error
+		body, err = json.Marshal(in)
+		if err != nil {
+			return err
+		}
+	}
+
+	resp, err := c.Do(ctx, method, path, nil, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if !statusExpected(resp.StatusCode, expected) {
+		return responseError(method, path, resp, respBody)
+	}
+
+	if out == nil || len(bytes.TrimSpace(respBody)) == 0 {
+		return nil
+	}
+	if raw, ok := out.(*[]byte); ok {
+		*raw = respBody
+		return nil
+	}
+	return json.Unmarshal(respBody, out)
+}
+
+func statusExpected(code int, expected []int) bool {
+	if len(expected) == 0 {
+		return code >= 200 && code <= 299
+	}
+	for _, e := range expected {
+		if code == e {
+			return true
+		}
+	}
+	return false
+}
+
+func responseError(method, path string, resp *http.Response, body []byte) error {
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return ErrSessionExpired
+	case http.StatusForbidden:
+		return ErrAccessDenied
+	}
+
+	var platformError struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &platformError) == nil {
+		message := platformError.Error
+		if message == "" {
+			message = platformError.Message
+		}
+		if message != "" {
+			return &APIError{StatusCode: resp.StatusCode, Message: message}
+		}
+	}
+
+	return &StatusError{
+		Method:     method,
+		Path:       path,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       strings.TrimSpace(string(body)),
+	}
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func isRetryableStatus(code int) bool {
+	return code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+// RefreshToken exchanges the current token for a new one.
+func (c *Client) RefreshToken(ctx context.Context) (string, error) {
+	resp, err := c.send(ctx, http.MethodPut, "/refresh_token", nil, nil, c.Token)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusAccepted, http.StatusCreated:
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return "", ErrNotSupported
+	default:
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", responseError(http.MethodPut, "/refresh_token", resp, body)
+	}
+
+	var loginResponse LoginResponse
+	err = json.NewDecoder(resp.Body).Decode(&loginResponse)
+	if err != nil {
+		return "", err
+	}
+	if loginResponse.Token == "" {
+		return "", ErrNotSupported
+	}
+
+	c.Token = loginResponse.Token
+	if c.OnTokenRefresh != nil {
+		c.OnTokenRefresh(c.Token)
+	}
+	return c.Token, nil
+}
+
+// IsSessionError reports whether err means the user has to log in again.
+func IsSessionError(err error) bool {
+	return errors.Is(err, ErrSessionExpired) || errors.Is(err, ErrAccessDenied)
+}
@@ -0,0 +1,399 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	c := New(server.URL, "old-token")
+	c.RetryDelay = time.Millisecond
+	return c
+}
+
+func TestRetryOnGatewayStatusForGet(t *testing.T) {
+	for _, status := range []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout} {
+		var calls int32
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) < 3 {
+				w.WriteHeader(status)
+				return
+			}
+			w.Write([]byte(`{"name":"Ann"}`))
+		})
+
+		info, err := c.UserInfo(context.Background())
+		if err != nil {
+			t.Fatalf("status %d: unexpected error: %v", status, err)
+		}
+		if info.Name != "Ann" {
+			t.Errorf("status %d: name = %q, want Ann", status, info.Name)
+		}
+		if atomic.LoadInt32(&calls) != 3 {
+			t.Errorf("status %d: calls = %d, want 3", status, calls)
+		}
+	}
+}
+
+func TestRetryGivesUpAfterRetries(t *testing.T) {
+	var calls int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	_, err := c.UserInfo(context.Background())
+	if !IsStatus(err, http.StatusServiceUnavailable) {
+		t.Fatalf("err = %v, want 503 status error", err)
+	}
+	if atomic.LoadInt32(&calls) != int32(1+DefaultRetries) {
+		t.Errorf("calls = %d, want %d", calls, 1+DefaultRetries)
+	}
+}
+
+func TestNoRetryForPost(t *testing.T) {
+	var calls int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	_, err := c.RunKuratorRequest(context.Background(), map[string]string{"type": "check"})
+	if !IsStatus(err, http.StatusBadGateway) {
+		t.Fatalf("err = %v, want 502 status error", err)
+	}
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
+
+func TestNoRetryForPut(t *testing.T) {
+	var calls int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	_, err := c.Login(context.Background(), "a@b.c", "secret")
+	if !IsStatus(err, http.StatusServiceUnavailable) {
+		t.Fatalf("err = %v, want 503 status error", err)
+	}
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	release := make(chan struct{})
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := c.UserInfo(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("request took %s after the context deadline", elapsed)
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	release := make(chan struct{})
+	var calls int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	defer close(release)
+	c.HTTPClient.Timeout = 20 * time.Millisecond
+	c.Retries = 0
+
+	_, err := c.UserInfo(context.Background())
+	if err == nil {
+		t.Fatal("expected a timeout error")
+	}
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
+
+func TestRefreshTokenOn401(t *testing.T) {
+	var infoCalls, refreshCalls int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/refresh_token":
+			atomic.AddInt32(&refreshCalls, 1)
+			if r.Method != http.MethodPut || r.Header.Get("Token") != "old-token" {
+				t.Errorf("refresh: %s with token %q", r.Method, r.Header.Get("Token"))
+			}
+			w.Write([]byte(`{"token":"new-token"}`))
+		case "/user_info":
+			atomic.AddInt32(&infoCalls, 1)
+			if r.Header.Get("Token") != "new-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"name":"Ann"}`))
+		}
+	})
+
+	var refreshed string
+	c.OnTokenRefresh = func(token string) { refreshed = token }
+
+	info, err := c.UserInfo(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Name != "Ann" {
+		t.Errorf("name = %q, want Ann", info.Name)
+	}
+	if atomic.LoadInt32(&infoCalls) != 2 || atomic.LoadInt32(&refreshCalls) != 1 {
+		t.Errorf("user_info calls = %d, refresh calls = %d, want 2 and 1", infoCalls, refreshCalls)
+	}
+	if c.Token != "new-token" || refreshed != "new-token" {
+		t.Errorf("token = %q, refreshed = %q, want new-token", c.Token, refreshed)
+	}
+}
+
+func TestRefreshTokenRetriesOnce(t *testing.T) {
+	var infoCalls, refreshCalls int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/refresh_token":
+			atomic.AddInt32(&refreshCalls, 1)
+			w.Write([]byte(`{"token":"new-token"}`))
+		default:
+			atomic.AddInt32(&infoCalls, 1)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+
+	_, err := c.UserInfo(context.Background())
+	if !errors.Is(err, ErrSessionExpired) {
+		t.Fatalf("err = %v, want ErrSessionExpired", err)
+	}
+	if atomic.LoadInt32(&infoCalls) != 2 || atomic.LoadInt32(&refreshCalls) != 1 {
+		t.Errorf("user_info calls = %d, refresh calls = %d, want 2 and 1", infoCalls, refreshCalls)
+	}
+}
+
+func TestRefreshNotSupported(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/refresh_token" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
+	_, err := c.UserInfo(context.Background())
+	if !errors.Is(err, ErrSessionExpired) {
+		t.Fatalf("err = %v, want ErrSessionExpired", err)
+	}
+	if _, err := c.RefreshToken(context.Background()); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("RefreshToken err = %v, want ErrNotSupported", err)
+	}
+}
+
+func TestSessionErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusUnauthorized, ErrSessionExpired},
+		{http.StatusForbidden, ErrAccessDenied},
+	}
+	for _, tt := range tests {
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+		})
+		// Without a token there is nothing to refresh
+		c.Token = ""
+
+		_, err := c.ListCourses(context.Background())
+		if !errors.Is(err, tt.want) {
+			t.Errorf("status %d: err = %v, want %v", tt.status, err, tt.want)
+		}
+		if !IsSessionError(err) {
+			t.Errorf("status %d: IsSessionError(%v) = false", tt.status, err)
+		}
+	}
+}
+
+func TestResponseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantAPI string
+	}{
+		{"error field", `{"error":"course not found"}`, "course not found"},
+		{"message field", `{"message":"course not found"}`, "course not found"},
+		{"plain text", `not found`, ""},
+		{"json without message", `{"code":42}`, ""},
+	}
+	for _, tt := range tests {
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(tt.body))
+		})
+
+		_, err := c.GetCourse(context.Background(), "k8s")
+		if !IsStatus(err, http.StatusNotFound) {
+			t.Errorf("%s: IsStatus(%v, 404) = false", tt.name, err)
+		}
+
+		var apiErr *APIError
+		var statusErr *StatusError
+		if tt.wantAPI != "" {
+			if !errors.As(err, &apiErr) || apiErr.Message != tt.wantAPI {
+				t.Errorf("%s: err = %#v, want APIError %q", tt.name, err, tt.wantAPI)
+			}
+			continue
+		}
+		if !errors.As(err, &statusErr) {
+			t.Errorf("%s: err = %#v, want StatusError", tt.name, err)
+			continue
+		}
+		if statusErr.Method != http.MethodGet || statusErr.Path != "/course/k8s" || statusErr.Body != tt.body {
+			t.Errorf("%s: status error = %+v", tt.name, statusErr)
+		}
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	type request struct {
+		method string
+		path   string
+		query  string
+		token  string
+		body   map[string]interface{}
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		query  string
+		status int
+		reply  string
+		call   func(c *Client) error
+	}{
+		{
+			name: "Login", method: http.MethodPut, path: "/auth_user", status: http.StatusAccepted,
+			reply: `{"token":"t1","user_id":7}`,
+			call: func(c *Client) error {
+				resp, err := c.Login(context.Background(), "a@b.c", "secret")
+				if err == nil && (resp.Token != "t1" || resp.UserID != 7) {
+					t.Errorf("Login response = %+v", resp)
+				}
+				return err
+			},
+		},
+		{
+			name: "UserInfo", method: http.MethodGet, path: "/user_info", status: http.StatusOK,
+			reply: `{"name":"Ann","email":"a@b.c"}`,
+			call: func(c *Client) error {
+				info, err := c.UserInfo(context.Background())
+				if err == nil && info.Email != "a@b.c" {
+					t.Errorf("UserInfo = %+v", info)
+				}
+				return err
+			},
+		},
+		{
+			name: "ListCourses", method: http.MethodGet, path: "/course_list", status: http.StatusOK,
+			reply: `[{"short_name":"k8s","is_paid":true}]`,
+			call: func(c *Client) error {
+				courses, err := c.ListCourses(context.Background())
+				if err == nil && (len(courses) != 1 || courses[0].ShortName != "k8s" || !courses[0].IsPaid) {
+					t.Errorf("ListCourses = %+v", courses)
+				}
+				return err
+			},
+		},
+		{
+			name: "RunKuratorRequest", method: http.MethodPost, path: "/run_kurator_request", status: http.StatusOK,
+			reply: `{"result":"ok"}`,
+			call: func(c *Client) error {
+				raw, err := c.RunKuratorRequest(context.Background(), map[string]string{"type": "check"})
+				if err == nil && string(raw) != `{"result":"ok"}` {
+					t.Errorf("RunKuratorRequest = %s", raw)
+				}
+				return err
+			},
+		},
+		{
+			name: "PublishCourse", method: http.MethodPost, path: "/course/k8s/bundle", query: "draft=true", status: http.StatusCreated,
+			reply: `{"short_name":"k8s","is_draft":true}`,
+			call: func(c *Client) error {
+				course, err := c.PublishCourse(context.Background(), "k8s", "1.0.0", "abc", true, []byte("bundle"))
+				if err == nil && (course.ShortName != "k8s" || !course.IsDraft) {
+					t.Errorf("PublishCourse = %+v", course)
+				}
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		var got request
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			got = request{method: r.Method, path: r.URL.Path, query: r.URL.RawQuery, token: r.Header.Get("Token")}
+			if r.Header.Get("Content-Type") == "application/json" {
+				body, _ := ioutil.ReadAll(r.Body)
+				json.Unmarshal(body, &got.body)
+			}
+			w.WriteHeader(tt.status)
+			w.Write([]byte(tt.reply))
+		})
+
+		if err := tt.call(c); err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if got.method != tt.method || got.path != tt.path || got.query != tt.query {
+			t.Errorf("%s: request = %s %s?%s, want %s %s?%s", tt.name, got.method, got.path, got.query, tt.method, tt.path, tt.query)
+		}
+		if got.token != "old-token" {
+			t.Errorf("%s: token header = %q", tt.name, got.token)
+		}
+		if tt.name == "Login" && (got.body["email"] != "a@b.c" || got.body["password"] != "secret") {
+			t.Errorf("Login body = %v", got.body)
+		}
+	}
+}
+
+func TestEndpointUnexpectedStatus(t *testing.T) {
+	// Login expects 202 Accepted; a plain 200 means the platform did not authorize
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"token":"t1"}`))
+	})
+
+	_, err := c.Login(context.Background(), "a@b.c", "secret")
+	if !IsStatus(err, http.StatusOK) {
+		t.Errorf("err = %v, want status error for 200", err)
+	}
+}
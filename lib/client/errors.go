@@ -0,0 +1,53 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrSessionExpired = errors.New("session expired, run `kurator login`")
+	ErrAccessDenied   = errors.New("access denied for this account. If your session expired run `kurator login`")
+
+	// ErrNotSupported is returned when the platform does not implement an endpoint.
+	ErrNotSupported = errors.New("not supported by the platform")
+)
+
+// StatusError is returned when the platform replies with an unexpected HTTP status.
+type StatusError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("%s %s: server returned status: %s", e.Method, e.Path, e.Status)
+	}
+	return fmt.Sprintf("%s %s: server returned status: %s. Response: %s", e.Method, e.Path, e.Status, e.Body)
+}
+
+// APIError is a platform error body ({"error": "..."}) returned with a non-success status.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// IsStatus reports whether err carries the given HTTP status code.
+func IsStatus(err error, code int) bool {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode == code
+	}
+	var ae *APIError
+	if errors.As(err, &ae) {
+		return ae.StatusCode == code
+	}
+	return false
+}
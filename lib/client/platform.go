@@ -0,0 +1,182 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+type LoginResponse struct {
+	Token  string `json:"token"`
+	UserID int64  `json:"user_id"`
+}
+
+type UserInfo struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type Course struct {
+	ShortName string `json:"short_name"`
+	FullName  string `json:"full_name"`
+	Author    string `json:"author"`
+	URL       string `json:"url"`
+	IsPaid    bool   `json:"is_paid"`
+	IsDraft   bool   `json:"is_draft"`
+}
+
+type TaskListItem struct {
+	TaskTitle      string `json:"taskTitle"`
+	TaskID         string `json:"taskID"`
+	IsCompleted    bool   `json:"isCompleted"`
+	IsLocked       bool   `json:"isLocked"`
+	PositionNumber int    `json:"positionNumber"`
+}
+
+// CourseDetails is the course page as seen by the current user.
+type CourseDetails struct {
+	CourseTitle    string         `json:"courseTitle"`
+	CourseIcon     string         `json:"courseIcon"`
+	AuthorName     string         `json:"authorName"`
+	AuthorPosition string         `json:"authorPosition"`
+	AuthorPhoto    string         `json:"authorPhoto"`
+	TaskList       []TaskListItem `json:"taskList"`
+}
+
+type GoalStatus struct {
+	ID          string `json:"id"`
+	IsCompleted bool   `json:"isCompleted"`
+}
+
+// CourseTask holds the parts of a task page needed to track progress.
+type CourseTask struct {
+	TaskTitle string       `json:"taskTitle"`
+	TaskID    string       `json:"taskID"`
+	Goals     []GoalStatus `json:"goals"`
+}
+
+// Login exchanges email and password for a bearer token.
+func (c *Client) Login(ctx context.Context, email, password string) (LoginResponse, error) {
+	var loginResponse LoginResponse
+	payload := map[string]string{
+		"email":    email,
+		"password": password,
+	}
+	err := c.call(ctx, http.MethodPut, "/auth_user", payload, &loginResponse, http.StatusAccepted)
+	return loginResponse, err
+}
+
+// Signup registers a new account. The password is sent to the email by the platform.
+func (c *Client) Signup(ctx context.Context, email, name string) error {
+	payload := struct {
+		Email string `json:"email"`
+		Name  string `json:"name"`
+	}{
+		Email: email,
+		Name:  name,
+	}
+	return c.call(ctx, http.MethodPost, "/users", payload, nil, http.StatusCreated)
+}
+
+// Logout ends the session of the client token on the platform.
+func (c *Client) Logout(ctx context.Context) error {
+	resp, err := c.send(ctx, http.MethodPut, "/logout_user", nil, []byte("{}"), c.Token)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// An expired session is already ended on the platform side
+	if resp.StatusCode == http.StatusUnauthorized || (resp.StatusCode >= 200 && resp.StatusCode <= 299) {
+		return nil
+	}
+	return responseError(http.MethodPut, "/logout_user", resp, nil)
+}
+
+// UserInfo returns the account the client token belongs to.
+func (c *Client) UserInfo(ctx context.Context) (UserInfo, error) {
+	var info UserInfo
+	err := c.call(ctx, http.MethodGet, "/user_info", nil, &info, http.StatusOK)
+	return info, err
+}
+
+// SendResetLink asks the platform to email a password reset link.
+func (c *Client) SendResetLink(ctx context.Context, email string) error {
+	payload := map[string]string{"email": email}
+	return c.call(ctx, http.MethodPost, "/send_reset_link", payload, nil)
+}
+
+// ChangePassword sets a new password for the account of the client token.
+func (c *Client) ChangePassword(ctx context.Context, oldPassword, newPassword string) error {
+	payload := map[string]string{
+		"old_password":   oldPassword,
+		"password":       newPassword,
+		"again_password": newPassword,
+	}
+	return c.call(ctx, http.MethodPut, "/change_password", payload, nil)
+}
+
+// DeleteAccount permanently removes the account of the client token.
+func (c *Client) DeleteAccount(ctx context.Context) error {
+	return c.call(ctx, http.MethodDelete, "/users", nil, nil)
+}
+
+func (c *Client) ListCourses(ctx context.Context) ([]Course, error) {
+	var courses []Course
+	err := c.call(ctx, http.MethodGet, "/course_list", nil, &courses, http.StatusOK)
+	return courses, err
+}
+
+// GetCourse returns course details and the task list with the user's completion state.
+func (c *Client) GetCourse(ctx context.Context, shortName string) (CourseDetails, error) {
+	var details CourseDetails
+	err := c.call(ctx, http.MethodGet, "/course/"+url.PathEscape(shortName), nil, &details, http.StatusOK)
+	return details, err
+}
+
+// GetCourseTask returns the task at taskNumber (1-based) with the user's goal completion state.
+func (c *Client) GetCourseTask(ctx context.Context, shortName string, taskNumber int) (CourseTask, error) {
+	var task CourseTask
+	err := c.call(ctx, http.MethodGet, fmt.Sprintf("/course/%s/%d", url.PathEscape(shortName), taskNumber), nil, &task, http.StatusOK)
+	return task, err
+}
+
+// RunKuratorRequest relays a kurator request to the student agent connected
+// to the platform and returns the raw agent response.
+func (c *Client) RunKuratorRequest(ctx context.Context, request interface{}) ([]byte, error) {
+	var result []byte
+	err := c.call(ctx, http.MethodPost, "/run_kurator_request", request, &result)
+	return result, err
+}
+
+// PublishCourse uploads a course bundle built by `kurator dev pack`. Draft
+// courses are visible only to their author until published without draft.
+func (c *Client) PublishCourse(ctx context.Context, shortName, version, checksum string, draft bool, bundle []byte) (Course, error) {
+	var course Course
+	path := fmt.Sprintf("/course/%s/bundle?draft=%t", url.PathEscape(shortName), draft)
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/gzip")
+	header.Set("X-Course-Version", version)
+	header.Set("X-Course-Checksum", "sha256:"+checksum)
+
+	resp, err := c.Do(ctx, http.MethodPost, path, header, bundle)
+	if err != nil {
+		return course, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return course, err
+	}
+	if !statusExpected(resp.StatusCode, []int{http.StatusOK, http.StatusCreated}) {
+		return course, responseError(http.MethodPost, path, resp, respBody)
+	}
+
+	err = json.Unmarshal(respBody, &course)
+	return course, err
+}
@@ -11,33 +11,37 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"gl.biggo.pro/devopstrain/kurator/lib/client"
 )
 
 var targetURL = "https://api.lifeisfile.com"
 
+const (
+	handlerTimeout = time.Minute
+	// kuratorRequestTimeout covers the round trip to the student agent including command run time
+	kuratorRequestTimeout = 2 * time.Minute
+)
+
+// CheckAuthCompleted returns the token from KURATOR_TOKEN if set, otherwise
+// the one stored for the active profile.
 func CheckAuthCompleted() (bool, string) {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return false, ""
+	if token := strings.TrimSpace(os.Getenv(tokenEnvVar)); token != "" {
+		return true, token
 	}
 
-	tokenPath := filepath.Join(homeDir, ".config", "kurator", "token")
-	_, err = os.Stat(tokenPath)
+	store, err := NewTokenStore()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return false, ""
-		}
 		return false, ""
 	}
 
-	tokenBytes, err := ioutil.ReadFile(tokenPath)
+	token, err := store.Get()
 	if err != nil {
 		return false, ""
 	}
 
-	token := string(tokenBytes)
 	return true, token
 }
 
@@ -59,14 +63,13 @@ func ReplaceText(filePath, modifiedFilePath, oldText, newText string) error {
 
 func ProxyHandler(method, path string) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		req, err := http.NewRequest(method, targetURL+path, c.Request().Body)
+		reqBody, err := ioutil.ReadAll(c.Request().Body)
 		if err != nil {
 			return err
 		}
 
-		req.Header = c.Request().Header
-
-		resp, err := http.DefaultClient.Do(req)
+		// Browser requests carry their own Token header, so the client token is left empty
+		resp, err := client.New(targetURL, "").Do(c.Request().Context(), method, path, c.Request().Header, reqBody)
 		if err != nil {
 			return err
 		}
@@ -125,33 +128,32 @@ func ProxyHandler(method, path string) echo.HandlerFunc {
 	}
 }
 
-func SendPostRequest(url, data, token string) (string, error) {
-	result := ""
-	var jsonStr = []byte(data)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonStr))
+// SendPostRequest posts JSON data to the course handler backend.
+func SendPostRequest(url, data string) (string, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBufferString(data))
+	if err != nil {
+		return "", err
+	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Token", token)
 
-	fmt.Println(url, data)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	httpClient := &http.Client{Timeout: handlerTimeout}
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		result = err.Error()
-	} else {
-		fmt.Println("response Status:", resp.Status)
-		fmt.Println("response Headers:", resp.Header)
-		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusAccepted {
-			return result, fmt.Errorf("Backend replied with %d status", resp.StatusCode)
-		}
+		return "", err
+	}
+	defer resp.Body.Close()
 
-		body, _ := ioutil.ReadAll(resp.Body)
-		result = string(body)
-		defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusAccepted {
+		return "", fmt.Errorf("Backend replied with %d status", resp.StatusCode)
 	}
 
-	return result, err
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	return string(body), nil
 }
 
 func GetUserIDFromToken(token string) (int64, error) {
@@ -192,6 +194,15 @@ func StringSliceContains(s []string, e string) bool {
 	return false
 }
 
+func IntSliceContains(s []int, e int) bool {
+	for _, a := range s {
+		if a == e {
+			return true
+		}
+	}
+	return false
+}
+
 func copyDirectory(src, dest string) error {
 	srcInfo, err := os.Stat(src)
 	if err != nil {
@@ -1,6 +1,7 @@
 package lib
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -11,60 +12,47 @@ import (
 	"os/exec"
 	"os/signal"
 	"runtime"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
-	"github.com/olekukonko/tablewriter"
 	"github.com/urfave/cli/v2"
+	"gl.biggo.pro/devopstrain/kurator/lib/client"
 )
 
-type Course struct {
-	ShortName string `json:"short_name"`
-	FullName  string `json:"full_name"`
-	Author    string `json:"author"`
-	URL       string `json:"url"`
-	IsPaid    bool   `json:"is_paid"`
-	IsDraft   bool   `json:"is_draft"`
-}
+type Course = client.Course
 
 func ListCourses(c *cli.Context) error {
-	authCompleted, token := CheckAuthCompleted()
-	if !authCompleted {
-		return fmt.Errorf("authentication not completed")
-	}
-
-	url := targetURL + "/course_list"
-
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	format := c.String("output")
+	err := checkOutputFormat(format)
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Token", token)
+	if c.Bool("paid") && c.Bool("free") {
+		return fmt.Errorf("--paid and --free can't be used together")
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
+	authCompleted, token := CheckAuthCompleted()
+	if !authCompleted {
+		return errorT("auth_not_completed")
 	}
-	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	courses, err := newPlatformClient(token).ListCourses(context.Background())
 	if err != nil {
 		return err
 	}
 
-	var courses []Course
-	err = json.Unmarshal(body, &courses)
+	courses = filterCourses(courses, c.Bool("paid"), c.Bool("free"), c.Bool("drafts"), c.String("author"))
+
+	err = sortCourses(courses, c.String("sort"), c.Bool("reverse"))
 	if err != nil {
 		return err
 	}
 
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"Short Name", "Full Name", "Author", "URL", "Payment", "Is Draft"})
-
+	var rows [][]string
 	for _, course := range courses {
-		table.Append([]string{
+		rows = append(rows, []string{
 			course.ShortName,
 			course.FullName,
 			course.Author,
@@ -74,24 +62,143 @@ func ListCourses(c *cli.Context) error {
 		})
 	}
 
-	table.Render()
+	if courses == nil {
+		courses = []Course{}
+	}
+	return renderOutput(format, []string{"Short Name", "Full Name", "Author", "URL", "Payment", "Is Draft"}, rows, courses)
+}
+
+func filterCourses(courses []Course, paid, free, drafts bool, author string) []Course {
+	var result []Course
+	for _, course := range courses {
+		if paid && !course.IsPaid {
+			continue
+		}
+		if free && course.IsPaid {
+			continue
+		}
+		if drafts && !course.IsDraft {
+			continue
+		}
+		if author != "" && !strings.Contains(strings.ToLower(course.Author), strings.ToLower(author)) {
+			continue
+		}
+		result = append(result, course)
+	}
+	return result
+}
+
+func sortCourses(courses []Course, field string, reverse bool) error {
+	var key func(course Course) string
+	switch field {
+	case "":
+		return nil
+	case "short_name":
+		key = func(course Course) string { return course.ShortName }
+	case "full_name":
+		key = func(course Course) string { return course.FullName }
+	case "author":
+		key = func(course Course) string { return course.Author }
+	default:
+		return fmt.Errorf("unknown sort field %q. Use one of: short_name, full_name, author", field)
+	}
 
+	sort.SliceStable(courses, func(i, j int) bool {
+		if reverse {
+			return strings.ToLower(key(courses[i])) > strings.ToLower(key(courses[j]))
+		}
+		return strings.ToLower(key(courses[i])) < strings.ToLower(key(courses[j]))
+	})
 	return nil
 }
 
+type CourseShowResult struct {
+	Course
+	client.CourseDetails
+}
+
+func ShowCourse(c *cli.Context) error {
+	shortName := c.Args().First()
+	if shortName == "" {
+		return errorT("course_name_missing")
+	}
+
+	format := c.String("output")
+	err := checkOutputFormat(format)
+	if err != nil {
+		return err
+	}
+
+	authCompleted, token := CheckAuthCompleted()
+	if !authCompleted {
+		return errorT("auth_not_completed")
+	}
+
+	pc := newPlatformClient(token)
+	courses, err := pc.ListCourses(context.Background())
+	if err != nil {
+		return err
+	}
+
+	result := CourseShowResult{}
+	found := false
+	for _, course := range courses {
+		if course.ShortName == shortName {
+			result.Course = course
+			found = true
+			break
+		}
+	}
+	if !found {
+		return errorT("course_not_found", shortName)
+	}
+
+	result.CourseDetails, err = pc.GetCourse(context.Background(), shortName)
+	if err != nil {
+		return err
+	}
+
+	if format == "json" || format == "yaml" {
+		return renderOutput(format, nil, nil, result)
+	}
+
+	var rows [][]string
+	for _, task := range result.TaskList {
+		rows = append(rows, []string{
+			fmt.Sprintf("%d", task.PositionNumber+1),
+			task.TaskID,
+			task.TaskTitle,
+			fmt.Sprintf("%t", task.IsCompleted),
+			fmt.Sprintf("%t", task.IsLocked),
+		})
+	}
+
+	if format == "table" {
+		author := strings.TrimSpace(result.AuthorName + " " + result.AuthorPosition)
+		if author == "" {
+			author = result.Author
+		}
+		fmt.Printf("Short Name: %s\nTitle: %s\nAuthor: %s\nURL: %s\nPayment: %t\nIs Draft: %t\n\n",
+			result.ShortName, result.CourseTitle, author, result.URL, result.IsPaid, result.IsDraft)
+	}
+
+	return renderOutput(format, []string{"#", "Task ID", "Title", "Completed", "Locked"}, rows, result)
+}
+
 func sendToken(conn *websocket.Conn, token string) error {
 
 	return conn.WriteMessage(websocket.TextMessage, []byte(token))
 }
 
-func handleServerMessage(conn *websocket.Conn, message []byte, isDev bool) {
+func handleServerMessage(conn *websocket.Conn, message []byte, isDev bool, redactor *Redactor) {
 	kr := KuratorRequest{}
 	err := json.Unmarshal(message, &kr)
 	if err == nil {
 		// it is JSON
 		if kr.IsDev && !isDev {
-			conn.WriteMessage(websocket.TextMessage, []byte(`{"error": "Run dev commands on non-dev client"}`))
-			fmt.Println("Refused to run local command")
+			dataJson, _ := json.Marshal(KuratorResponse{SeqID: kr.SeqID, Error: T(localLangs(), "start_dev_only")})
+			conn.WriteMessage(websocket.TextMessage, dataJson)
+			fmt.Println(T(localLangs(), "start_dev_refused"))
 			return
 		}
 		kresp := KuratorResponse{
@@ -107,13 +214,13 @@ func handleServerMessage(conn *websocket.Conn, message []byte, isDev bool) {
 			case "windows":
 				cmd = exec.Command("powershell", "-Command", kr.Payload)
 			default:
-				fmt.Printf("Unsupported operating system: %s", os)
+				fmt.Println(T(localLangs(), "start_unsupported_os", os))
 			}
-			fmt.Println("Command run", kr.Payload)
+			fmt.Println(T(localLangs(), "start_command_run", kr.Payload))
 			output, err := cmd.CombinedOutput()
 			exitCode := cmd.ProcessState.ExitCode()
 			if err != nil {
-				fmt.Println("Failed to run command:", err)
+				fmt.Println(T(localLangs(), "start_command_failed", err))
 				fmt.Println(string(output))
 			}
 			kresp.CommandOutput = string(output)
@@ -152,18 +259,49 @@ func handleServerMessage(conn *websocket.Conn, message []byte, isDev bool) {
 		default:
 			kresp.CommandOutput = "TYPE_NOT_SUPPORTED:" + version
 		}
+		redactor.RedactResponse(&kresp)
+		if kresp.RedactionCount > 0 {
+			fmt.Println(T(localLangs(), "start_redacted", kresp.RedactionCount))
+		}
 		dataJson, _ := json.Marshal(kresp)
 
 		conn.WriteMessage(websocket.TextMessage, dataJson)
 	}
 }
 
+// websocketURL converts the platform API URL into its websocket endpoint.
+func websocketURL(apiURL string) (*url.URL, error) {
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	default:
+		u.Scheme = "wss"
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/ws"
+
+	return u, nil
+}
+
 func StartCourse(c *cli.Context) error {
 	isDev := c.Bool("dev")
 
-	authCompleted, token := CheckAuthCompleted()
-	if !authCompleted {
-		return fmt.Errorf("authentication not completed")
+	if c.String("api-url") != "" {
+		targetURL = strings.TrimSuffix(c.String("api-url"), "/")
+	}
+
+	redactor, err := NewRedactor(c.StringSlice("redact-pattern"))
+	if err != nil {
+		return err
+	}
+
+	token, err := requireValidSession()
+	if err != nil {
+		return err
 	}
 
 	interrupt := make(chan os.Signal, 1)
@@ -172,7 +310,7 @@ func StartCourse(c *cli.Context) error {
 	go func() {
 		// Wait for the interrupt signal
 		<-interrupt
-		fmt.Println("\nInterrupt signal received. Exiting...")
+		fmt.Println("\n" + T(localLangs(), "start_interrupted"))
 		if conn != nil {
 			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 		}
@@ -180,16 +318,22 @@ func StartCourse(c *cli.Context) error {
 		os.Exit(0)
 	}()
 
-	u := url.URL{Scheme: "wss", Host: "api.lifeisfile.com", Path: "/ws"}
-	log.Printf("Connecting to %s", u.String())
+	u, err := websocketURL(targetURL)
+	if err != nil {
+		return err
+	}
+	log.Println(T(localLangs(), "start_connecting", u.String()))
 
 	done := make(chan struct{})
 
 	for {
 
-		conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+		conn, resp, err := websocket.DefaultDialer.Dial(u.String(), nil)
+		if resp != nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+			return client.ErrSessionExpired
+		}
 		if err != nil {
-			log.Println("Connection failed. Retrying in 5 seconds...")
+			log.Println(T(localLangs(), "start_retrying"))
 			time.Sleep(5 * time.Second)
 			continue
 		}
@@ -198,12 +342,12 @@ func StartCourse(c *cli.Context) error {
 		err = sendToken(conn, token)
 		if err != nil {
 			conn.Close()
-			log.Println("Connection failed. Retrying in 5 seconds...")
+			log.Println(T(localLangs(), "start_retrying"))
 			time.Sleep(5 * time.Second)
 			continue
 		}
 
-		log.Println("Connected!")
+		log.Println(T(localLangs(), "start_connected"))
 
 		// Start a goroutine to handle incoming messages from the server
 		go func() {
@@ -216,11 +360,15 @@ func StartCourse(c *cli.Context) error {
 							log.Printf("error: %v", err)
 							os.Exit(0)
 						}
+						if websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+							fmt.Println(client.ErrSessionExpired)
+							os.Exit(1)
+						}
 						if websocket.IsCloseError(err, websocket.CloseAbnormalClosure) {
 							log.Printf("error: %v", err)
 							conn, _, err = websocket.DefaultDialer.Dial(u.String(), nil)
 							if err != nil {
-								log.Println("Connection failed. Retrying in 5 seconds...")
+								log.Println(T(localLangs(), "start_retrying"))
 								time.Sleep(5 * time.Second)
 								continue
 							} else {
@@ -232,11 +380,11 @@ func StartCourse(c *cli.Context) error {
 					}
 					log.Printf("Received message from server: %s\n", message)
 
-					handleServerMessage(conn, message, isDev)
+					handleServerMessage(conn, message, isDev, redactor)
 				} else {
 					conn, _, err = websocket.DefaultDialer.Dial(u.String(), nil)
 					if err != nil {
-						log.Println("Connection failed. Retrying in 5 seconds...")
+						log.Println(T(localLangs(), "start_retrying"))
 						time.Sleep(5 * time.Second)
 						continue
 					} else {
@@ -14,14 +14,12 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
-	"sort"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/urfave/cli/v2"
-	"gopkg.in/yaml.v2"
+	"gl.biggo.pro/devopstrain/kurator/lib/client"
 )
 
 var (
@@ -50,6 +48,10 @@ func extractEmbeddedFiles(embeddedFiles embed.FS, targetPath string) error {
 		}
 
 		targetFilePath := filepath.Join(targetPath, path)
+		err = os.MkdirAll(filepath.Dir(targetFilePath), 0755)
+		if err != nil {
+			return err
+		}
 
 		err = ioutil.WriteFile(targetFilePath, content, 0644)
 		if err != nil {
@@ -67,16 +69,36 @@ func extractEmbeddedFiles(embeddedFiles embed.FS, targetPath string) error {
 	return nil
 }
 
-func extraMiddleware(handlerURL string) echo.MiddlewareFunc {
+type devServerConfig struct {
+	HandlerURL   string
+	SimulatePaid bool
+	Offline      bool
+	Hub          *agentHub
+	Lang         string
+}
+
+func extraMiddleware(cfg devServerConfig) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			c.Set("handlerURL", handlerURL)
+			c.Set("handlerURL", cfg.HandlerURL)
+			c.Set("simulatePaid", cfg.SimulatePaid)
+			c.Set("offline", cfg.Offline)
+			c.Set("agentHub", cfg.Hub)
+			c.Set("lang", cfg.Lang)
 			return next(c)
 		}
 	}
 }
 
 func RunDevServer(c *cli.Context) error {
+	offline := c.Bool("offline")
+	if !offline {
+		_, err := requireValidSession()
+		if err != nil {
+			return err
+		}
+	}
+
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return err
@@ -107,8 +129,32 @@ func RunDevServer(c *cli.Context) error {
 		log.Fatal(err)
 	}
 
+	indexFilePath := webPath + "/web/index.html"
+	err = ReplaceText(indexFilePath, indexFilePath, "</body>", reloadScriptTag+"</body>")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	warnings, err := taskFileWarnings(courseName)
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		fmt.Println("warning:", warning)
+	}
+
+	broker := newReloadBroker(courseName)
+	go broker.Watch(make(chan struct{}))
+
 	e := echo.New()
-	e.Use(extraMiddleware(c.String("handler_url")))
+	hub := newAgentHub()
+	e.Use(extraMiddleware(devServerConfig{
+		HandlerURL:   c.String("handler_url"),
+		SimulatePaid: c.Bool("simulate-paid"),
+		Offline:      offline,
+		Hub:          hub,
+		Lang:         strings.ToLower(c.String("lang")),
+	}))
 
 	e.Static("/", webPath+"/web")
 
@@ -117,6 +163,11 @@ func RunDevServer(c *cli.Context) error {
 		if he, ok := err.(*echo.HTTPError); ok {
 			code = he.Code
 		}
+		var ye *YAMLError
+		if errors.As(err, &ye) {
+			c.JSON(http.StatusUnprocessableEntity, ye)
+			return
+		}
 		if code == http.StatusNotFound {
 			c.File(webPath + "/web/index.html")
 		} else {
@@ -124,12 +175,25 @@ func RunDevServer(c *cli.Context) error {
 		}
 	}
 
-	e.PUT("/auth_user", ProxyHandler("PUT", "/auth_user"))
-	e.PUT("/logout_user", ProxyHandler("PUT", "/logout_user"))
+	if offline {
+		e.PUT("/auth_user", offlineAuthUser)
+		e.PUT("/logout_user", func(c echo.Context) error { return c.JSON(http.StatusOK, ResponseEmpty{}) })
+		e.GET("/user_info", offlineUserInfo)
+	} else {
+		e.PUT("/auth_user", ProxyHandler("PUT", "/auth_user"))
+		e.PUT("/logout_user", ProxyHandler("PUT", "/logout_user"))
+		e.GET("/user_info", ProxyHandler("GET", "/user_info"))
+	}
+
+	// Local agents connect here instead of the platform, see `kurator course start --api-url`
+	e.GET("/ws", hub.ServeWS)
+
+	e.GET("/dev/events", broker.ServeEvents)
 
 	e.GET("/course/:name", GetCourse)
-	e.GET("/course/:name/:taskNumber", GetCourseTask)
+	e.GET("/course/:name/:task", GetCourseTask)
 	e.POST("/baseHandler", BaseHandler)
+	e.POST("/quiz_answer", AnswerQuiz)
 
 	e.POST("/save_widget_status", SaveWidgetStatus)
 	e.POST("/get_widget_status", GetWidgetStatus)
@@ -140,60 +204,33 @@ func RunDevServer(c *cli.Context) error {
 		return c.File(dir + "/media/" + file)
 	})
 
-	e.Logger.Fatal(e.Start(":4321"))
+	// Agents run author commands, so the dev server is only reachable from this machine
+	e.Logger.Fatal(e.Start(devServerAddr))
 	return nil
 }
 
 func GetCourse(c echo.Context) error {
 	name := c.Param("name")
-	dat, err := ioutil.ReadFile(fmt.Sprintf("%s/base.yaml", name))
+	langs := requestLangs(c)
+
+	ci, err := loadCourseInfo(name, langs...)
 	if err != nil {
-		fmt.Println(err)
+		return err
 	}
-
-	ci := CourseInfo{}
-
-	err = yaml.Unmarshal(dat, &ci)
+	tasks, err := loadCourseTasks(name, langs...)
 	if err != nil {
 		return err
 	}
 
-	files, err := ioutil.ReadDir(fmt.Sprintf("%s/tasks", name))
+	completedTasks, err := loadCompletedTasks(name, devUserID(c))
 	if err != nil {
 		return err
 	}
-	var sortedFiles []string
-	for _, f := range files {
-		sortedFiles = append(sortedFiles, f.Name())
-	}
-	sort.Slice(sortedFiles, func(i, j int) bool {
-		var prev, next int
-		parts := strings.Split(sortedFiles[i], ".")
-		if len(parts) > 0 {
-			prev, _ = strconv.Atoi(parts[0])
-		}
-		parts = strings.Split(sortedFiles[j], ".")
-		if len(parts) > 0 {
-			next, _ = strconv.Atoi(parts[0])
-		}
-		return prev < next
-	})
-	var taskList []TaskListItem
 
-	var completedTasks []string
-	//TODO: implement list of completed tasks
-
-	for n, filename := range sortedFiles {
-		tsi := TaskShortInfo{}
-		dat, err = ioutil.ReadFile(fmt.Sprintf("%s/tasks/%s", name, filename))
-		if err != nil {
-			return err
-		}
-		err = yaml.Unmarshal(dat, &tsi)
-		if err != nil {
-			return err
-		}
-		locked := false
+	var taskList []TaskListItem
+	for n, task := range tasks {
+		tsi := task.Info
+		locked := taskLockReason(tsi.IsFree, tsi.DependsOn, completedTasks, simulatePaid(c), langs) != ""
 
 		isCompleted := false
 		if StringSliceContains(completedTasks, tsi.TaskID) {
@@ -209,48 +246,19 @@ func GetCourse(c echo.Context) error {
 func GetCourseTask(c echo.Context) error {
 
 	name := c.Param("name")
-	taskNumber := c.Param("taskNumber")
-	dat, err := ioutil.ReadFile(fmt.Sprintf("%s/tasks/%s.yaml", name, taskNumber))
+	task, err := resolveCourseTask(name, c.Param("task"), requestLangs(c)...)
 	if err != nil {
 		return err
 	}
-	ti := TaskInfo{}
-	err = yaml.Unmarshal(dat, &ti)
+	ti := task.Info
+	// kuratorRequest is not serialized to JSON, only dependsOn and quiz answers have to be hidden
+	ti.DependsOn = []string{}
+	stripQuizAnswers(&ti)
+
+	err = markGoalsCompleted(&ti, name, devUserID(c))
 	if err != nil {
 		return err
 	}
-	ti.DependsOn = []string{}
-	for n, goal := range ti.Goals {
-		for k, _ := range goal.Contents {
-			ti.Goals[n].Contents[k].KuratorRequest.Payload = ""
-			ti.Goals[n].Contents[k].KuratorRequest.APIVersion = ""
-			ti.Goals[n].Contents[k].KuratorRequest.Type = ""
-			ti.Goals[n].Contents[k].KuratorRequest.Command = ""
-			ti.Goals[n].Contents[k].KuratorRequest.Files = []string{}
-			ti.Goals[n].Contents[k].KuratorRequest.Args = []string{}
-		}
-	}
-
-	//TODO: implement
-	// resultJSON, err := storage.ReadObj(fmt.Sprintf("goal-list-%d", tkn.UserID))
-	// if err != nil {
-	// 	raven.CaptureError(err, nil)
-	// 	return err
-	// }
-	// var completedGoals []string
-	// if string(resultJSON) != "{}" {
-	// 	err = json.Unmarshal(resultJSON, &completedGoals)
-	// 	if err != nil {
-	// 		raven.CaptureError(err, nil)
-	// 	}
-	// }
-	// for _, goal := range completedGoals {
-	// 	for n, stepGoal := range ti.Goals {
-	// 		if goal == stepGoal.ID {
-	// 			ti.Goals[n].IsCompleted = true
-	// 		}
-	// 	}
-	// }
 
 	return c.JSON(http.StatusOK, ti)
 }
@@ -260,8 +268,11 @@ func BaseHandler(c echo.Context) error {
 	handlerURL := c.Get("handlerURL").(string)
 	token := c.Request().Header.Get("Token")
 
+	offline, _ := c.Get("offline").(bool)
+	hub, _ := c.Get("agentHub").(*agentHub)
+
 	authCompleted, devtoken := CheckAuthCompleted()
-	if !authCompleted {
+	if !authCompleted && !offline {
 		return fmt.Errorf("authentication not completed")
 	}
 
@@ -280,22 +291,29 @@ func BaseHandler(c echo.Context) error {
 		fmt.Println(userID)
 	}
 
-	dat, err := ioutil.ReadFile(fmt.Sprintf("%s/tasks/%d.yaml", rh.CourseName, rh.TaskNumber))
+	langs := requestLangs(c)
+	task, err := resolveCourseTask(rh.CourseName, taskRef(rh.TaskID, rh.TaskNumber))
 	if err != nil {
 		return err
 	}
-	ti := TaskInfo{}
-	err = yaml.Unmarshal(dat, &ti)
+	ti := task.Info
+
+	rh.IsPaid = simulatePaid(c)
+
+	completedTasks, err := loadCompletedTasks(rh.CourseName, rh.UserID)
 	if err != nil {
 		return err
 	}
+	if reason := taskLockReason(ti.IsFree, ti.DependsOn, completedTasks, rh.IsPaid, langs); reason != "" {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": T(langs, "task_locked", reason)})
+	}
 
 	for _, goal := range ti.Goals {
 
 		//Check if current method has client websocket dependency call
 		for _, content := range goal.Contents {
 			if content.KuratorRequest.Payload != "" {
-				if goal.RunHandler == rh.Method || (content.SourceHandler == rh.Method) {
+				if goal.RunHandler == rh.Method || content.OutputHandler() == rh.Method {
 					//TODO: Check for rh.CacheKey, use own cache to return the result to avoid hitting the client when result is cached on handler side
 					// Client websocket call is required
 					rand.Seed(time.Now().UnixNano())
@@ -311,30 +329,27 @@ func BaseHandler(c echo.Context) error {
 						CourseName: rh.CourseName,
 						SeqID:      int64(randomNumber),
 					}
-					dataJson, _ := json.Marshal(kr)
-					result, err := SendPostRequest(targetURL+"/run_kurator_request", string(dataJson), devtoken)
+					kresp, err := runKuratorRequest(c.Request().Context(), hub, offline, kr, devtoken)
+					if client.IsSessionError(err) {
+						return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+					}
 					if err != nil {
-						if content.SourceHandler == rh.Method {
+						if content.OutputHandler() == rh.Method {
 							res := OutputResult{
 								ResultType:     "markdown",
-								ResultContents: "```\nОшибка: **Kurator** должен быть запущен в директории с исходным кодом: `kurator course start`\n```",
+								ResultContents: T(langs, "kurator_not_running_output"),
 								IsReady:        true,
 							}
 							return c.JSON(http.StatusOK, res)
 						} else {
 							res := CheckStatusResult{
 								Status:   "user_error",
-								Expected: "**Kurator** должен быть запущен: `kurator course start -dev`",
-								Current:  "**Kurator** не подключен к серверу",
+								Expected: T(langs, "kurator_not_running_expected"),
+								Current:  T(langs, "kurator_not_connected"),
 							}
 							return c.JSON(http.StatusOK, res)
 						}
 					}
-					kresp := KuratorResponse{}
-					err = json.Unmarshal([]byte(result), &kresp)
-					if err != nil {
-						return c.JSON(http.StatusInternalServerError, ResponseEmpty{})
-					}
 					rh.KuratorCommandExitCode = kresp.CommandExitCode
 					rh.KuratorCommandOutput = kresp.CommandOutput
 					rh.BoolResponse = kresp.BoolResponse
@@ -343,10 +358,8 @@ func BaseHandler(c echo.Context) error {
 		}
 	}
 
-	//
-	// rh.IsPaid = isPaid
 	dataJson, _ := json.Marshal(rh)
-	result, err := SendPostRequest(handlerURL, string(dataJson), "")
+	result, err := SendPostRequest(handlerURL, string(dataJson))
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
@@ -357,11 +370,11 @@ func BaseHandler(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, ResponseEmpty{})
 	}
 	if rfh.Status == "done" {
-		//Mark this goal as completed for user
-		fmt.Println("Task number is", rh.TaskNumber)
-		//Check if all goals of current task are completed and if so mark task as completed as well
-		//TODO: implement
-		//UpdateUserGoals(rh)
+		//Mark this goal as completed for user and the task as well when all its goals are completed
+		err = UpdateUserGoals(rh, ti)
+		if err != nil {
+			fmt.Println("Failed to save progress:", err)
+		}
 	}
 	return c.JSONBlob(http.StatusOK, []byte(result))
 }
@@ -382,6 +395,15 @@ func CreateCourse(c *cli.Context) error {
 		return err
 	}
 
+	err = writeCourseSchemas(courseName)
+	if err != nil {
+		return err
+	}
+	err = addCourseModelines(courseName)
+	if err != nil {
+		return err
+	}
+
 	fmt.Printf("Initial structure for %s course is created in folder %s. You may now start editing these yamls and view them in web browser. Be sure to start server using:\n\n", courseName, courseName)
 	fmt.Printf("kurator dev run-server --course_name %s --handler_url http://localhost:8888/courseHandler \n\n", courseName)
 
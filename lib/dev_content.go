@@ -0,0 +1,386 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ContentBlock is the kind specific part of a goal content item.
+type ContentBlock interface {
+	// OutputHandler returns the handler method that renders the block, if any.
+	OutputHandler() string
+	// Validate reports mistakes in the block. File and Line are filled in by the caller.
+	Validate() []Problem
+}
+
+type contentKind struct {
+	Name string
+	New  func() ContentBlock
+}
+
+// contentKinds is the registry of content kinds supported by the course UI.
+// Add a new kind here together with its ContentBlock type.
+var contentKinds = []contentKind{
+	{"text", func() ContentBlock { return &TextBlock{} }},
+	{"tabs", func() ContentBlock { return &TabsBlock{} }},
+	{"button", func() ContentBlock { return &ButtonBlock{} }},
+	{"server", func() ContentBlock { return &ServerBlock{} }},
+	{"whatHappened", func() ContentBlock { return &WhatHappenedBlock{} }},
+	{"quiz", func() ContentBlock { return &QuizBlock{} }},
+}
+
+func knownContentKinds() []string {
+	var names []string
+	for _, kind := range contentKinds {
+		names = append(names, kind.Name)
+	}
+	return names
+}
+
+// newContentBlock returns an empty block of kind or nil when the kind is unknown.
+func newContentBlock(kind string) ContentBlock {
+	for _, k := range contentKinds {
+		if k.Name == kind {
+			return k.New()
+		}
+	}
+	return nil
+}
+
+type Goal struct {
+	ID            string    `json:"id" yaml:"id"`
+	StatusHandler string    `json:"statusHandler" yaml:"statusHandler,omitempty"`
+	RunHandler    string    `json:"runHandler" yaml:"runHandler,omitempty"`
+	IsCompleted   bool      `json:"isCompleted" yaml:"-"`
+	Contents      []Content `json:"contents" yaml:"contents"`
+}
+
+type Faq struct {
+	Question string `json:"question" yaml:"question"`
+	Answer   string `json:"answer" yaml:"answer"`
+}
+
+// KuratorRequestSpec describes the request sent to the student agent when the
+// goal handler runs. It is never sent to the browser.
+type KuratorRequestSpec struct {
+	APIVersion string   `json:"apiVersion,omitempty" yaml:"apiVersion,omitempty"`
+	Type       string   `json:"type,omitempty" yaml:"type,omitempty"`
+	Payload    string   `json:"payload,omitempty" yaml:"payload,omitempty"`
+	Command    string   `json:"command,omitempty" yaml:"command,omitempty"`
+	Args       []string `json:"args,omitempty" yaml:"args,omitempty"`
+	Files      []string `json:"files,omitempty" yaml:"files,omitempty"`
+}
+
+func (kr KuratorRequestSpec) IsEmpty() bool {
+	return kr.Type == "" && kr.Payload == ""
+}
+
+// Content is a goal content item: the kind, the block of that kind and an
+// optional kurator request. In YAML and JSON the block fields are flattened
+// next to kind.
+type Content struct {
+	Kind           string
+	Block          ContentBlock
+	KuratorRequest KuratorRequestSpec
+}
+
+type contentHeader struct {
+	Kind           string             `json:"kind" yaml:"kind"`
+	KuratorRequest KuratorRequestSpec `json:"kuratorRequest" yaml:"kuratorRequest"`
+}
+
+// OutputHandler returns the handler method that renders the content, if any.
+func (c Content) OutputHandler() string {
+	if c.Block == nil {
+		return ""
+	}
+	return c.Block.OutputHandler()
+}
+
+// Validate reports mistakes in the content. Unknown kinds are kept as is so
+// the browser can still render kinds added to the UI later.
+func (c Content) Validate() []Problem {
+	if c.Kind == "" {
+		return []Problem{{Severity: SeverityError, Message: "content has no kind"}}
+	}
+	if newContentBlock(c.Kind) == nil {
+		return []Problem{{Severity: SeverityError, Field: "kind", Message: fmt.Sprintf("unknown content kind %q. Known kinds: %s", c.Kind, strings.Join(knownContentKinds(), ", "))}}
+	}
+	return c.Block.Validate()
+}
+
+func (c *Content) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	header := contentHeader{}
+	err := unmarshal(&header)
+	if err != nil {
+		return err
+	}
+
+	block := newContentBlock(header.Kind)
+	if block == nil {
+		block = &UnknownBlock{}
+	}
+	err = unmarshal(block)
+	if err != nil {
+		return err
+	}
+
+	*c = Content{Kind: header.Kind, Block: block, KuratorRequest: header.KuratorRequest}
+	return nil
+}
+
+func (c Content) MarshalYAML() (interface{}, error) {
+	fields := yaml.MapSlice{{Key: "kind", Value: c.Kind}}
+	if c.Block != nil {
+		dat, err := yaml.Marshal(c.Block)
+		if err != nil {
+			return nil, err
+		}
+		var blockFields yaml.MapSlice
+		err = yaml.Unmarshal(dat, &blockFields)
+		if err != nil {
+			return nil, err
+		}
+		for _, field := range blockFields {
+			if field.Key != "kind" {
+				fields = append(fields, field)
+			}
+		}
+	}
+	if !c.KuratorRequest.IsEmpty() {
+		fields = append(fields, yaml.MapItem{Key: "kuratorRequest", Value: c.KuratorRequest})
+	}
+	return fields, nil
+}
+
+func (c *Content) UnmarshalJSON(data []byte) error {
+	header := contentHeader{}
+	err := json.Unmarshal(data, &header)
+	if err != nil {
+		return err
+	}
+
+	block := newContentBlock(header.Kind)
+	if block == nil {
+		block = &UnknownBlock{}
+	}
+	err = json.Unmarshal(data, block)
+	if err != nil {
+		return err
+	}
+
+	*c = Content{Kind: header.Kind, Block: block, KuratorRequest: header.KuratorRequest}
+	return nil
+}
+
+// MarshalJSON keeps the flat shape expected by the course UI. The kurator
+// request is left out, it must not reach the browser.
+func (c Content) MarshalJSON() ([]byte, error) {
+	fields := map[string]interface{}{}
+	if c.Block != nil {
+		dat, err := json.Marshal(c.Block)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(dat, &fields)
+		if err != nil {
+			return nil, err
+		}
+	}
+	fields["kind"] = c.Kind
+	return json.Marshal(fields)
+}
+
+type TextBlock struct {
+	Content string `json:"content,omitempty" yaml:"content,omitempty"`
+}
+
+func (b *TextBlock) OutputHandler() string { return "" }
+
+func (b *TextBlock) Validate() []Problem {
+	if b.Content == "" {
+		return []Problem{{Severity: SeverityWarning, Message: "text content is empty"}}
+	}
+	return nil
+}
+
+type WhatHappenedBlock struct {
+	Content string `json:"content,omitempty" yaml:"content,omitempty"`
+}
+
+func (b *WhatHappenedBlock) OutputHandler() string { return "" }
+
+func (b *WhatHappenedBlock) Validate() []Problem {
+	if b.Content == "" {
+		return []Problem{{Severity: SeverityWarning, Message: "whatHappened content is empty"}}
+	}
+	return nil
+}
+
+type Tab struct {
+	Title   string `json:"title" yaml:"title"`
+	Content string `json:"content" yaml:"content"`
+}
+
+type TabsBlock struct {
+	Tabs []Tab `json:"tabs,omitempty" yaml:"tabs,omitempty"`
+}
+
+func (b *TabsBlock) OutputHandler() string { return "" }
+
+func (b *TabsBlock) Validate() []Problem {
+	if len(b.Tabs) == 0 {
+		return []Problem{{Severity: SeverityError, Message: "tabs content has no tabs"}}
+	}
+	var problems []Problem
+	for n, tab := range b.Tabs {
+		if tab.Title == "" {
+			problems = append(problems, Problem{Severity: SeverityWarning, Field: "tabs", Message: fmt.Sprintf("tab #%d has no title", n+1)})
+		}
+	}
+	return problems
+}
+
+type ButtonBlock struct {
+	ID             string `json:"id,omitempty" yaml:"id,omitempty"`
+	Text           string `json:"text,omitempty" yaml:"text,omitempty"`
+	DisableOnClick int    `json:"disableOnClick,omitempty" yaml:"disableOnClick,omitempty"`
+}
+
+func (b *ButtonBlock) OutputHandler() string { return "" }
+
+func (b *ButtonBlock) Validate() []Problem {
+	var problems []Problem
+	if b.ID == "" {
+		problems = append(problems, Problem{Severity: SeverityError, Message: "button has no id"})
+	}
+	if b.Text == "" {
+		problems = append(problems, Problem{Severity: SeverityWarning, Message: "button has no text"})
+	}
+	return problems
+}
+
+type ServerBlock struct {
+	SourceHandler string `json:"sourceHandler,omitempty" yaml:"sourceHandler,omitempty"`
+	CacheKey      string `json:"cacheKey,omitempty" yaml:"cacheKey,omitempty"`
+}
+
+func (b *ServerBlock) OutputHandler() string { return b.SourceHandler }
+
+func (b *ServerBlock) Validate() []Problem {
+	if b.SourceHandler == "" {
+		return []Problem{{Severity: SeverityError, Message: "server content has no sourceHandler"}}
+	}
+	return nil
+}
+
+type QuizAnswer struct {
+	Text      string `json:"text,omitempty" yaml:"text,omitempty"`
+	IsCorrect bool   `json:"isCorrect,omitempty" yaml:"isCorrect,omitempty"`
+}
+
+type QuizBlock struct {
+	ID            string       `json:"id,omitempty" yaml:"id,omitempty"`
+	Title         string       `json:"title,omitempty" yaml:"title,omitempty"`
+	AllowMultiple bool         `json:"allowMultiple,omitempty" yaml:"allowMultiple,omitempty"`
+	Answers       []QuizAnswer `json:"answers" yaml:"answers"`
+}
+
+func (b *QuizBlock) OutputHandler() string { return "" }
+
+func (b *QuizBlock) hasCorrectAnswer() bool {
+	for _, answer := range b.Answers {
+		if answer.IsCorrect {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *QuizBlock) Validate() []Problem {
+	if b.ID == "" {
+		return []Problem{{Severity: SeverityError, Message: "quiz has no id"}}
+	}
+	if len(b.Answers) < 2 {
+		return []Problem{{Severity: SeverityError, Field: "answers", Message: "quiz needs at least two answers"}}
+	}
+
+	correct := 0
+	for _, answer := range b.Answers {
+		if answer.IsCorrect {
+			correct++
+		}
+	}
+	switch {
+	case correct == 0:
+		return []Problem{{Severity: SeverityError, Field: "answers", Message: "quiz has no correct answer"}}
+	case correct > 1 && !b.AllowMultiple:
+		return []Problem{{Severity: SeverityError, Field: "answers", Message: "quiz has several correct answers, set allowMultiple: true"}}
+	}
+	return nil
+}
+
+// UnknownBlock keeps the fields of a kind missing from the registry.
+type UnknownBlock struct {
+	Fields map[string]interface{}
+}
+
+func (b *UnknownBlock) OutputHandler() string { return "" }
+
+func (b *UnknownBlock) Validate() []Problem { return nil }
+
+func (b *UnknownBlock) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var fields map[string]interface{}
+	err := unmarshal(&fields)
+	if err != nil {
+		return err
+	}
+	delete(fields, "kind")
+	delete(fields, "kuratorRequest")
+	b.Fields = jsonCompatible(fields).(map[string]interface{})
+	return nil
+}
+
+func (b UnknownBlock) MarshalYAML() (interface{}, error) {
+	return b.Fields, nil
+}
+
+func (b *UnknownBlock) UnmarshalJSON(data []byte) error {
+	err := json.Unmarshal(data, &b.Fields)
+	if err != nil {
+		return err
+	}
+	delete(b.Fields, "kind")
+	delete(b.Fields, "kuratorRequest")
+	return nil
+}
+
+func (b UnknownBlock) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.Fields)
+}
+
+// jsonCompatible converts maps decoded by yaml.v2 to maps with string keys.
+func jsonCompatible(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for key, item := range value {
+			m[fmt.Sprint(key)] = jsonCompatible(item)
+		}
+		return m
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = jsonCompatible(item)
+		}
+		return value
+	case []interface{}:
+		for n, item := range value {
+			value[n] = jsonCompatible(item)
+		}
+		return value
+	}
+	return v
+}
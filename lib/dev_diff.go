@@ -0,0 +1,405 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Change kinds of `kurator dev diff`
+const (
+	ChangeCourse         = "course_changed"
+	ChangeTaskAdded      = "task_added"
+	ChangeTaskRemoved    = "task_removed"
+	ChangeTaskMoved      = "task_moved"
+	ChangeTask           = "task_changed"
+	ChangeGoalAdded      = "goal_added"
+	ChangeGoalRemoved    = "goal_removed"
+	ChangeGoalRenamed    = "goal_renamed"
+	ChangeHandler        = "handler_changed"
+	ChangeKuratorRequest = "kurator_request_changed"
+	ChangeContent        = "content_changed"
+	ChangeFaq            = "faq_changed"
+)
+
+// CourseChange is one difference between two versions of a course. Breaking
+// changes lose or lock progress of students who started the old version.
+type CourseChange struct {
+	Change   string `json:"change"`
+	TaskID   string `json:"taskID,omitempty"`
+	GoalID   string `json:"goalID,omitempty"`
+	Field    string `json:"field,omitempty"`
+	Old      string `json:"old,omitempty"`
+	New      string `json:"new,omitempty"`
+	Breaking bool   `json:"breaking"`
+}
+
+type CourseDiff struct {
+	Old      string         `json:"old"`
+	New      string         `json:"new"`
+	Breaking bool           `json:"breaking"`
+	Changes  []CourseChange `json:"changes"`
+}
+
+type courseVersion struct {
+	info  CourseInfo
+	tasks []courseTaskFile
+}
+
+// loadCourseVersion loads a course folder or a bundle built by `kurator dev pack`.
+func loadCourseVersion(source string) (courseVersion, error) {
+	cv := courseVersion{}
+	courseDir := source
+
+	fi, err := os.Stat(source)
+	if err != nil {
+		return cv, err
+	}
+	if !fi.IsDir() {
+		bundle, err := ioutil.ReadFile(source)
+		if err != nil {
+			return cv, err
+		}
+		courseDir, err = ioutil.TempDir("", "kurator-diff-")
+		if err != nil {
+			return cv, err
+		}
+		defer os.RemoveAll(courseDir)
+		err = extractBundle(bundle, courseDir)
+		if err != nil {
+			return cv, fmt.Errorf("%s: %w", source, err)
+		}
+	}
+
+	cv.info, err = loadCourseInfo(courseDir)
+	if err != nil {
+		return cv, err
+	}
+	cv.tasks, err = loadCourseTasks(courseDir)
+	return cv, err
+}
+
+type courseDiffer struct {
+	changes []CourseChange
+}
+
+func (d *courseDiffer) add(change CourseChange) {
+	d.changes = append(d.changes, change)
+}
+
+func (d *courseDiffer) field(change, taskID, goalID, field, old, new string, breaking bool) {
+	if old != new {
+		d.add(CourseChange{Change: change, TaskID: taskID, GoalID: goalID, Field: field, Old: old, New: new, Breaking: breaking})
+	}
+}
+
+// commonOrder returns ids present in both lists that keep their relative
+// order, the longest common subsequence. Other common ids were moved.
+func commonOrder(old, new []string) map[string]bool {
+	lcs := make([][]int, len(old)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(new)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if old[i] == new[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	kept := map[string]bool{}
+	for i, j := 0, 0; i < len(old) && j < len(new); {
+		switch {
+		case old[i] == new[j]:
+			kept[old[i]] = true
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return kept
+}
+
+func (d *courseDiffer) diffCourse(old, new courseVersion) {
+	d.field(ChangeCourse, "", "", "courseTitle", old.info.CourseTitle, new.info.CourseTitle, false)
+	d.field(ChangeCourse, "", "", "baseServerHandlerURL", old.info.BaseServerHandlerURL, new.info.BaseServerHandlerURL, false)
+
+	oldTasks := map[string]TaskInfo{}
+	var oldIDs, newIDs []string
+	for _, task := range old.tasks {
+		oldTasks[task.Info.TaskID] = task.Info
+		oldIDs = append(oldIDs, task.Info.TaskID)
+	}
+	newTasks := map[string]TaskInfo{}
+	for _, task := range new.tasks {
+		newTasks[task.Info.TaskID] = task.Info
+		newIDs = append(newIDs, task.Info.TaskID)
+	}
+
+	kept := commonOrder(oldIDs, newIDs)
+	for n, id := range oldIDs {
+		if _, ok := newTasks[id]; !ok {
+			d.add(CourseChange{Change: ChangeTaskRemoved, TaskID: id, Old: oldTasks[id].TaskTitle, Breaking: true})
+			continue
+		}
+		if !kept[id] {
+			newPosition := 0
+			for m, newID := range newIDs {
+				if newID == id {
+					newPosition = m + 1
+				}
+			}
+			d.add(CourseChange{Change: ChangeTaskMoved, TaskID: id, Field: "position", Old: strconv.Itoa(n + 1), New: strconv.Itoa(newPosition)})
+		}
+	}
+	for n, id := range newIDs {
+		oldTask, ok := oldTasks[id]
+		if !ok {
+			d.add(CourseChange{Change: ChangeTaskAdded, TaskID: id, Field: "position", New: strconv.Itoa(n + 1)})
+			continue
+		}
+		d.diffTask(oldTask, newTasks[id])
+	}
+}
+
+func (d *courseDiffer) diffTask(old, new TaskInfo) {
+	id := new.TaskID
+	d.field(ChangeTask, id, "", "taskTitle", old.TaskTitle, new.TaskTitle, false)
+	d.field(ChangeTask, id, "", "intro", strings.TrimSpace(old.Intro), strings.TrimSpace(new.Intro), false)
+	// A paid task or a new dependency locks the task for students who already opened it
+	d.field(ChangeTask, id, "", "isFree", strconv.FormatBool(old.IsFree), strconv.FormatBool(new.IsFree), old.IsFree && !new.IsFree)
+	addedDeps := false
+	for _, dep := range new.DependsOn {
+		addedDeps = addedDeps || !StringSliceContains(old.DependsOn, dep)
+	}
+	d.field(ChangeTask, id, "", "dependsOn", strings.Join(old.DependsOn, ", "), strings.Join(new.DependsOn, ", "), addedDeps)
+
+	oldGoals := map[string]Goal{}
+	for _, goal := range old.Goals {
+		oldGoals[goal.ID] = goal
+	}
+	newGoals := map[string]Goal{}
+	for _, goal := range new.Goals {
+		newGoals[goal.ID] = goal
+	}
+
+	// A goal replaced by another id at the same place is reported as renamed
+	renamed := map[string]string{}
+	for n, goal := range old.Goals {
+		if _, ok := newGoals[goal.ID]; ok || n >= len(new.Goals) {
+			continue
+		}
+		if _, ok := oldGoals[new.Goals[n].ID]; !ok {
+			renamed[new.Goals[n].ID] = goal.ID
+		}
+	}
+
+	for _, goal := range old.Goals {
+		if _, ok := newGoals[goal.ID]; ok {
+			continue
+		}
+		isRenamed := false
+		for _, oldID := range renamed {
+			isRenamed = isRenamed || oldID == goal.ID
+		}
+		if !isRenamed {
+			d.add(CourseChange{Change: ChangeGoalRemoved, TaskID: id, GoalID: goal.ID, Breaking: true})
+		}
+	}
+	for _, goal := range new.Goals {
+		oldGoal, ok := oldGoals[goal.ID]
+		if oldID, isRenamed := renamed[goal.ID]; isRenamed {
+			d.add(CourseChange{Change: ChangeGoalRenamed, TaskID: id, GoalID: goal.ID, Field: "id", Old: oldID, New: goal.ID, Breaking: true})
+			oldGoal, ok = oldGoals[oldID], true
+		}
+		if !ok {
+			d.add(CourseChange{Change: ChangeGoalAdded, TaskID: id, GoalID: goal.ID})
+			continue
+		}
+		d.diffGoal(id, oldGoal, goal)
+	}
+
+	for n := 0; n < len(old.Faqs) || n < len(new.Faqs); n++ {
+		var oldFaq, newFaq string
+		if n < len(old.Faqs) {
+			oldFaq = old.Faqs[n].Question + "\n" + old.Faqs[n].Answer
+		}
+		if n < len(new.Faqs) {
+			newFaq = new.Faqs[n].Question + "\n" + new.Faqs[n].Answer
+		}
+		d.field(ChangeFaq, id, "", fmt.Sprintf("faqs[%d]", n), strings.TrimSpace(oldFaq), strings.TrimSpace(newFaq), false)
+	}
+}
+
+// trimStrings trims texts so that whitespace around them, which is not rendered, is not a change.
+func trimStrings(v interface{}) interface{} {
+	switch value := v.(type) {
+	case string:
+		return strings.TrimSpace(value)
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = trimStrings(item)
+		}
+	case []interface{}:
+		for n, item := range value {
+			value[n] = trimStrings(item)
+		}
+	}
+	return v
+}
+
+func contentText(content Content) string {
+	dat, err := json.Marshal(content)
+	if err != nil {
+		return fmt.Sprint(content.Block)
+	}
+	var fields interface{}
+	err = json.Unmarshal(dat, &fields)
+	if err != nil {
+		return string(dat)
+	}
+	dat, err = json.Marshal(trimStrings(fields))
+	if err != nil {
+		return fmt.Sprint(content.Block)
+	}
+	return string(dat)
+}
+
+func kuratorRequestText(kr KuratorRequestSpec) string {
+	if kr.IsEmpty() {
+		return ""
+	}
+	dat, _ := json.Marshal(kr)
+	return string(dat)
+}
+
+func (d *courseDiffer) diffGoal(taskID string, old, new Goal) {
+	d.field(ChangeHandler, taskID, new.ID, "statusHandler", old.StatusHandler, new.StatusHandler, false)
+	d.field(ChangeHandler, taskID, new.ID, "runHandler", old.RunHandler, new.RunHandler, false)
+
+	for n := 0; n < len(old.Contents) || n < len(new.Contents); n++ {
+		var oldContent, newContent Content
+		if n < len(old.Contents) {
+			oldContent = old.Contents[n]
+		}
+		if n < len(new.Contents) {
+			newContent = new.Contents[n]
+		}
+		field := fmt.Sprintf("contents[%d]", n)
+
+		oldText, newText := "", ""
+		if oldContent.Block != nil {
+			oldText = contentText(oldContent)
+		}
+		if newContent.Block != nil {
+			newText = contentText(newContent)
+		}
+		d.field(ChangeContent, taskID, new.ID, field, oldText, newText, false)
+
+		if oldContent.Block != nil && newContent.Block != nil && oldContent.OutputHandler() != newContent.OutputHandler() {
+			d.field(ChangeHandler, taskID, new.ID, field+".sourceHandler", oldContent.OutputHandler(), newContent.OutputHandler(), false)
+		}
+		if !reflect.DeepEqual(oldContent.KuratorRequest, newContent.KuratorRequest) {
+			d.field(ChangeKuratorRequest, taskID, new.ID, field+".kuratorRequest", kuratorRequestText(oldContent.KuratorRequest), kuratorRequestText(newContent.KuratorRequest), false)
+		}
+	}
+}
+
+// diffCourses compares two versions of a course, tasks are matched by taskID and goals by id.
+func diffCourses(old, new courseVersion) []CourseChange {
+	d := &courseDiffer{changes: []CourseChange{}}
+	d.diffCourse(old, new)
+	return d.changes
+}
+
+// diffExcerpts shortens old and new for the table starting a bit before the first difference.
+func diffExcerpts(old, new string) (string, string) {
+	oldRunes := []rune(strings.Join(strings.Fields(old), " "))
+	newRunes := []rune(strings.Join(strings.Fields(new), " "))
+
+	start := 0
+	for start < len(oldRunes) && start < len(newRunes) && oldRunes[start] == newRunes[start] {
+		start++
+	}
+	start -= 20
+	if start < 0 {
+		start = 0
+	}
+
+	excerpt := func(runes []rune) string {
+		prefix := ""
+		if start > 0 && start < len(runes) {
+			runes, prefix = runes[start:], "..."
+		}
+		if len(runes) > 60 {
+			return prefix + string(runes[:57]) + "..."
+		}
+		return prefix + string(runes)
+	}
+	return excerpt(oldRunes), excerpt(newRunes)
+}
+
+func DiffCourseCLI(c *cli.Context) error {
+	format := c.String("output")
+	err := checkOutputFormat(format)
+	if err != nil {
+		return err
+	}
+	if c.NArg() != 2 {
+		return fmt.Errorf("usage: kurator dev diff [options] <old-dir|bundle> <new-dir|bundle>")
+	}
+
+	oldCourse, err := loadCourseVersion(c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	newCourse, err := loadCourseVersion(c.Args().Get(1))
+	if err != nil {
+		return err
+	}
+
+	diff := CourseDiff{Old: c.Args().Get(0), New: c.Args().Get(1), Changes: diffCourses(oldCourse, newCourse)}
+	breakingCount := 0
+	var rows [][]string
+	for _, change := range diff.Changes {
+		breaking := ""
+		if change.Breaking {
+			breaking = "yes"
+			breakingCount++
+		}
+		old, new := diffExcerpts(change.Old, change.New)
+		rows = append(rows, []string{change.Change, change.TaskID, change.GoalID, change.Field, old, new, breaking})
+	}
+	diff.Breaking = breakingCount > 0
+
+	if format == "table" && len(rows) == 0 {
+		fmt.Println("Courses are the same")
+		return nil
+	}
+	err = renderOutput(format, []string{"Change", "Task", "Goal", "Field", "Old", "New", "Breaking"}, rows, diff)
+	if err != nil {
+		return err
+	}
+	if format == "table" {
+		fmt.Printf("%d change(s), %d breaking\n", len(diff.Changes), breakingCount)
+	}
+
+	if diff.Breaking && c.Bool("fail-on-breaking") {
+		return cli.Exit("Course has breaking changes", 1)
+	}
+	return nil
+}
@@ -0,0 +1,317 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/russross/blackfriday/v2"
+	"github.com/urfave/cli/v2"
+)
+
+var exportPageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ .Title }}</title>
+<style>
+body { max-width: 860px; margin: 0 auto; padding: 24px; font-family: sans-serif; line-height: 1.5; color: #222; }
+nav { display: flex; justify-content: space-between; border-bottom: 1px solid #ddd; padding-bottom: 8px; margin-bottom: 24px; }
+pre { background: #f5f5f5; padding: 12px; overflow: auto; }
+blockquote { border-left: 4px solid #ccc; margin-left: 0; padding-left: 12px; color: #555; }
+img { max-width: 100%; }
+</style>
+</head>
+<body>
+<nav>{{ .Nav }}</nav>
+{{ .Body }}
+</body>
+</html>
+`))
+
+type exportPage struct {
+	Title string
+	Nav   template.HTML
+	Body  template.HTML
+}
+
+type courseExporter struct {
+	name        string
+	courseDir   string
+	info        CourseInfo
+	tasks       []courseTaskFile
+	hideAnswers bool
+	// taskLink returns the link to the task at the given 1-based position.
+	// Positions are used rather than file numbers, which may have gaps, so
+	// that they match /course/:name/:task of the dev server.
+	taskLink func(position int) string
+}
+
+func newCourseExporter(courseDir string, hideAnswers bool) (*courseExporter, error) {
+	info, err := loadCourseInfo(courseDir)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := loadCourseTasks(courseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &courseExporter{
+		name:        filepath.Base(filepath.Clean(courseDir)),
+		courseDir:   courseDir,
+		info:        info,
+		tasks:       tasks,
+		hideAnswers: hideAnswers,
+	}, nil
+}
+
+// media rewrites dev server media URLs to the copied media folder.
+func (e *courseExporter) media(text string) string {
+	return strings.ReplaceAll(text, "/media/"+e.name+"/", "media/")
+}
+
+// taskByID returns the task with taskID and its 1-based position.
+func (e *courseExporter) taskByID(taskID string) (courseTaskFile, int, bool) {
+	for n, task := range e.tasks {
+		if task.Info.TaskID == taskID {
+			return task, n + 1, true
+		}
+	}
+	return courseTaskFile{}, 0, false
+}
+
+func (e *courseExporter) courseMarkdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", e.info.CourseTitle)
+	if e.info.AuthorName != "" {
+		fmt.Fprintf(&b, "*%s", e.info.AuthorName)
+		if e.info.AuthorPosition != "" {
+			fmt.Fprintf(&b, ", %s", e.info.AuthorPosition)
+		}
+		b.WriteString("*\n\n")
+	}
+
+	b.WriteString("## Tasks\n\n")
+	for n, task := range e.tasks {
+		fmt.Fprintf(&b, "%d. [%s](%s)\n", n+1, task.Info.TaskTitle, e.taskLink(n+1))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func (e *courseExporter) taskMarkdown(task courseTaskFile, position int) string {
+	ti := task.Info
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "<a id=\"task-%d\"></a>\n\n## %d. %s\n\n", position, position, ti.TaskTitle)
+	if !ti.IsFree {
+		b.WriteString("*Paid task*\n\n")
+	}
+	if len(ti.DependsOn) > 0 {
+		var deps []string
+		for _, dep := range ti.DependsOn {
+			if depTask, depPosition, ok := e.taskByID(dep); ok {
+				deps = append(deps, fmt.Sprintf("[%s](%s)", depTask.Info.TaskTitle, e.taskLink(depPosition)))
+			} else {
+				deps = append(deps, dep)
+			}
+		}
+		fmt.Fprintf(&b, "*Complete first: %s*\n\n", strings.Join(deps, ", "))
+	}
+	if ti.Intro != "" {
+		fmt.Fprintf(&b, "%s\n\n", strings.TrimSpace(e.media(ti.Intro)))
+	}
+
+	for n, goal := range ti.Goals {
+		fmt.Fprintf(&b, "### Step %d\n\n", n+1)
+		for _, content := range goal.Contents {
+			b.WriteString(e.contentMarkdown(content))
+		}
+	}
+
+	if len(ti.Faqs) > 0 {
+		b.WriteString("### FAQ\n\n")
+		for _, faq := range ti.Faqs {
+			fmt.Fprintf(&b, "**%s**\n\n%s\n\n", strings.TrimSpace(faq.Question), strings.TrimSpace(e.media(faq.Answer)))
+		}
+	}
+	return b.String()
+}
+
+func (e *courseExporter) contentMarkdown(content Content) string {
+	var b strings.Builder
+
+	switch block := content.Block.(type) {
+	case *TextBlock:
+		fmt.Fprintf(&b, "%s\n\n", strings.TrimSpace(e.media(block.Content)))
+	case *WhatHappenedBlock:
+		fmt.Fprintf(&b, "#### What happened\n\n%s\n\n", strings.TrimSpace(e.media(block.Content)))
+	case *TabsBlock:
+		for _, tab := range block.Tabs {
+			fmt.Fprintf(&b, "**%s**\n\n%s\n\n", tab.Title, strings.TrimSpace(e.media(tab.Content)))
+		}
+	case *ButtonBlock:
+		fmt.Fprintf(&b, "> Button: **%s**\n\n", block.Text)
+	case *ServerBlock:
+		fmt.Fprintf(&b, "> Output of `%s` is shown here\n\n", block.SourceHandler)
+	case *QuizBlock:
+		fmt.Fprintf(&b, "**Quiz: %s**", block.Title)
+		if block.AllowMultiple {
+			b.WriteString(" (several answers)")
+		}
+		b.WriteString("\n\n")
+		for _, answer := range block.Answers {
+			mark := " "
+			if answer.IsCorrect && !e.hideAnswers {
+				mark = "x"
+			}
+			fmt.Fprintf(&b, "- [%s] %s\n", mark, answer.Text)
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// exportMarkdown writes the whole course into a single <name>.md file.
+func (e *courseExporter) exportMarkdown(outputDir string) (string, error) {
+	e.taskLink = func(position int) string { return fmt.Sprintf("#task-%d", position) }
+
+	var b strings.Builder
+	b.WriteString(e.courseMarkdown())
+	for n, task := range e.tasks {
+		b.WriteString("---\n\n")
+		b.WriteString(e.taskMarkdown(task, n+1))
+	}
+
+	path := filepath.Join(outputDir, e.name+".md")
+	return path, ioutil.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// exportMarkdownSource writes the whole course into a single <name>.md file in
+// the convention of `kurator dev import-md`, so it can be edited and imported back.
+func (e *courseExporter) exportMarkdownSource(outputDir string) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<!-- %s. Import changes with: kurator dev import-md --course_name %s %s.md -->\n\n", e.info.CourseTitle, e.name, e.name)
+	for _, task := range e.tasks {
+		md, err := e.importableTaskMarkdown(task)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(md)
+	}
+
+	path := filepath.Join(outputDir, e.name+".md")
+	return path, ioutil.WriteFile(path, []byte(b.String()), 0644)
+}
+
+func renderExportPage(path, title string, nav []string, markdown string) error {
+	body := blackfriday.Run([]byte(markdown), blackfriday.WithExtensions(blackfriday.CommonExtensions))
+
+	var buf bytes.Buffer
+	err := exportPageTemplate.Execute(&buf, exportPage{
+		Title: title,
+		Nav:   template.HTML(strings.Join(nav, "")),
+		Body:  template.HTML(body),
+	})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// exportHTML writes index.html with the task list and a page per task.
+func (e *courseExporter) exportHTML(outputDir string) (string, error) {
+	e.taskLink = func(position int) string { return fmt.Sprintf("task-%d.html", position) }
+
+	index := filepath.Join(outputDir, "index.html")
+	err := renderExportPage(index, e.info.CourseTitle, nil, e.courseMarkdown())
+	if err != nil {
+		return "", err
+	}
+
+	for n, task := range e.tasks {
+		nav := []string{"<span></span>", `<a href="index.html">Contents</a>`, "<span></span>"}
+		if n > 0 {
+			prev := e.tasks[n-1]
+			nav[0] = fmt.Sprintf(`<a href="%s">&larr; %s</a>`, e.taskLink(n), template.HTMLEscapeString(prev.Info.TaskTitle))
+		}
+		if n < len(e.tasks)-1 {
+			next := e.tasks[n+1]
+			nav[2] = fmt.Sprintf(`<a href="%s">%s &rarr;</a>`, e.taskLink(n+2), template.HTMLEscapeString(next.Info.TaskTitle))
+		}
+
+		title := fmt.Sprintf("%s: %s", e.info.CourseTitle, task.Info.TaskTitle)
+		err = renderExportPage(filepath.Join(outputDir, e.taskLink(n+1)), title, nav, e.taskMarkdown(task, n+1))
+		if err != nil {
+			return "", err
+		}
+	}
+	return index, nil
+}
+
+func (e *courseExporter) copyMedia(outputDir string) error {
+	src := filepath.Join(e.courseDir, "media")
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+
+	dest := filepath.Join(outputDir, "media")
+	err := os.RemoveAll(dest)
+	if err != nil {
+		return err
+	}
+	return copyDirectory(src, dest)
+}
+
+func ExportCourseCLI(c *cli.Context) error {
+	courseDir := c.String("course_name")
+	format := c.String("format")
+	if format != "html" && format != "markdown" && format != "markdown-src" {
+		return fmt.Errorf("unknown format %s. Use html, markdown or markdown-src", format)
+	}
+	// Answers hidden from the source would be lost on import-md
+	if format == "markdown-src" && c.Bool("hide-answers") {
+		return fmt.Errorf("--hide-answers can't be used with markdown-src, importing it back would drop the correct quiz answers")
+	}
+
+	e, err := newCourseExporter(courseDir, c.Bool("hide-answers"))
+	if err != nil {
+		return err
+	}
+
+	outputDir := c.String("output")
+	if outputDir == "" {
+		outputDir = e.name + "-export"
+	}
+	err = os.MkdirAll(outputDir, 0755)
+	if err != nil {
+		return err
+	}
+
+	var path string
+	switch format {
+	case "html":
+		path, err = e.exportHTML(outputDir)
+	case "markdown":
+		path, err = e.exportMarkdown(outputDir)
+	default:
+		path, err = e.exportMarkdownSource(outputDir)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = e.copyMedia(outputDir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Course %s is exported to %s\n", e.name, path)
+	return nil
+}
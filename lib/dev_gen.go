@@ -4,12 +4,10 @@ import (
 	"html/template"
 	"io/ioutil"
 	"os"
-	"path"
 	"path/filepath"
 	"strings"
 
 	"github.com/urfave/cli/v2"
-	"gopkg.in/yaml.v2"
 )
 
 func GenerateHandlerCode(c *cli.Context) error {
@@ -17,7 +15,7 @@ func GenerateHandlerCode(c *cli.Context) error {
 	outputPath := c.String("output_path")
 	courseName := c.String("course_name")
 	moduleName := c.String("module_name")
-	taskInfos, err := parseYAMLFiles(path.Join(courseName, "tasks"))
+	taskInfos, err := parseYAMLFiles(courseName)
 	if err != nil {
 		return err
 	}
@@ -26,74 +24,33 @@ func GenerateHandlerCode(c *cli.Context) error {
 	return err
 }
 
-func parseYAMLFiles(dir string) ([]TaskInfo, error) {
-	var taskInfos []TaskInfo
-
-	files, err := ioutil.ReadDir(dir)
+// parseYAMLFiles loads the tasks of the course with includes and variables expanded.
+func parseYAMLFiles(courseDir string) ([]TaskInfo, error) {
+	tasks, err := loadCourseTasks(courseDir)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, file := range files {
-		if file.IsDir() || filepath.Ext(file.Name()) != ".yaml" {
-			continue
-		}
-
-		filePath := filepath.Join(dir, file.Name())
-		taskInfo, err := parseYAMLFile(filePath)
-		if err != nil {
-			return nil, err
-		}
-
-		taskInfos = append(taskInfos, taskInfo)
+	var taskInfos []TaskInfo
+	for _, task := range tasks {
+		taskInfos = append(taskInfos, task.Info)
 	}
-
 	return taskInfos, nil
 }
 
-func parseYAMLFile(filePath string) (TaskInfo, error) {
-	var taskInfo TaskInfo
-
-	yamlFile, err := os.Open(filePath)
-	if err != nil {
-		return taskInfo, err
-	}
-	defer yamlFile.Close()
-
-	yamlData, err := ioutil.ReadAll(yamlFile)
-	if err != nil {
-		return taskInfo, err
-	}
-
-	err = yaml.Unmarshal(yamlData, &taskInfo)
-	if err != nil {
-		return taskInfo, err
-	}
-
-	return taskInfo, nil
-}
-
 func GenerateGenTasks(taskInfos []TaskInfo) []GenTask {
 	var genTasks []GenTask
 
 	for _, taskInfo := range taskInfos {
 		genTask := GenTask{
-			TaskID: taskInfo.TaskID,
-			IsFree: taskInfo.IsFree,
-			Methods: make([]struct {
-				HandlerName     string
-				HandlerFuncName string
-				IsOutput        bool
-			}, 0),
+			TaskID:  taskInfo.TaskID,
+			IsFree:  taskInfo.IsFree,
+			Methods: make([]GenMethod, 0),
 		}
 
 		for _, goal := range taskInfo.Goals {
 			if goal.StatusHandler != "" {
-				genTask.Methods = append(genTask.Methods, struct {
-					HandlerName     string
-					HandlerFuncName string
-					IsOutput        bool
-				}{
+				genTask.Methods = append(genTask.Methods, GenMethod{
 					HandlerName:     goal.StatusHandler,
 					HandlerFuncName: convertToCamelCase(goal.StatusHandler),
 					IsOutput:        false,
@@ -101,30 +58,20 @@ func GenerateGenTasks(taskInfos []TaskInfo) []GenTask {
 			}
 
 			if goal.RunHandler != "" {
-				genTask.Methods = append(genTask.Methods, struct {
-					HandlerName     string
-					HandlerFuncName string
-					IsOutput        bool
-				}{
+				genTask.Methods = append(genTask.Methods, GenMethod{
 					HandlerName:     goal.RunHandler,
 					HandlerFuncName: convertToCamelCase(goal.RunHandler),
 					IsOutput:        false,
 				})
 			}
 
-			if goal.Contents != nil {
-				for _, content := range goal.Contents {
-					if content.SourceHandler != "" {
-						genTask.Methods = append(genTask.Methods, struct {
-							HandlerName     string
-							HandlerFuncName string
-							IsOutput        bool
-						}{
-							HandlerName:     content.SourceHandler,
-							HandlerFuncName: convertToCamelCase(content.SourceHandler),
-							IsOutput:        true,
-						})
-					}
+			for _, content := range goal.Contents {
+				if handler := content.OutputHandler(); handler != "" {
+					genTask.Methods = append(genTask.Methods, GenMethod{
+						HandlerName:     handler,
+						HandlerFuncName: convertToCamelCase(handler),
+						IsOutput:        true,
+					})
 				}
 			}
 		}
@@ -0,0 +1,238 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+)
+
+var errNoAgent = errorT("agent_not_connected")
+
+const devServerAddr = "127.0.0.1:4321"
+
+var wsUpgrader = websocket.Upgrader{CheckOrigin: sameOrigin}
+
+// sameOrigin accepts agents, which send no Origin, and pages served by the dev
+// server itself. Other websites open in the author's browser are refused.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// agentHub is the dev server counterpart of the platform websocket relay.
+// Student agents started with `kurator course start --api-url http://localhost:4321`
+// connect to /ws and receive kurator requests; replies are matched by SeqID.
+type agentHub struct {
+	mu      sync.Mutex
+	agents  map[int64]*agentConn
+	pending map[int64]chan KuratorResponse
+}
+
+type agentConn struct {
+	userID  int64
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+}
+
+func newAgentHub() *agentHub {
+	return &agentHub{
+		agents:  map[int64]*agentConn{},
+		pending: map[int64]chan KuratorResponse{},
+	}
+}
+
+// HasAgent reports whether an agent that can serve userID is connected.
+func (h *agentHub) HasAgent(userID int64) bool {
+	return h.agentFor(userID) != nil
+}
+
+// agentFor returns the agent of userID, or the only connected agent when the
+// user is unknown to it (e.g. browser and agent logged in with different tokens).
+func (h *agentHub) agentFor(userID int64) *agentConn {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if agent, ok := h.agents[userID]; ok {
+		return agent
+	}
+	if len(h.agents) == 1 {
+		for _, agent := range h.agents {
+			return agent
+		}
+	}
+	return nil
+}
+
+// ServeWS accepts an agent connection. The first message is the agent token.
+func (h *agentHub) ServeWS(c echo.Context) error {
+	conn, err := wsUpgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		return nil
+	}
+
+	userID, err := GetUserIDFromToken(string(message))
+	if err != nil {
+		userID = 0
+	}
+
+	agent := &agentConn{userID: userID, conn: conn}
+	h.mu.Lock()
+	if previous, ok := h.agents[userID]; ok {
+		previous.conn.Close()
+	}
+	h.agents[userID] = agent
+	h.mu.Unlock()
+	log.Printf("Kurator agent connected (user %d)", userID)
+
+	defer func() {
+		h.mu.Lock()
+		if h.agents[userID] == agent {
+			delete(h.agents, userID)
+		}
+		h.mu.Unlock()
+		log.Printf("Kurator agent disconnected (user %d)", userID)
+	}()
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return nil
+		}
+
+		kresp := KuratorResponse{}
+		err = json.Unmarshal(message, &kresp)
+		if err != nil {
+			log.Printf("Unexpected message from agent: %s", message)
+			continue
+		}
+
+		h.mu.Lock()
+		ch, ok := h.pending[kresp.SeqID]
+		if ok {
+			delete(h.pending, kresp.SeqID)
+		}
+		h.mu.Unlock()
+
+		if !ok {
+			log.Printf("Response for unknown request %d", kresp.SeqID)
+			continue
+		}
+		ch <- kresp
+	}
+}
+
+// Run sends kr to the agent of kr.UserID and waits for its response.
+func (h *agentHub) Run(ctx context.Context, kr KuratorRequest) (KuratorResponse, error) {
+	agent := h.agentFor(kr.UserID)
+	if agent == nil {
+		return KuratorResponse{}, errNoAgent
+	}
+
+	ch := make(chan KuratorResponse, 1)
+	h.mu.Lock()
+	for kr.SeqID == 0 || h.pending[kr.SeqID] != nil {
+		kr.SeqID = rand.Int63()
+	}
+	h.pending[kr.SeqID] = ch
+	h.mu.Unlock()
+
+	defer func() {
+		h.mu.Lock()
+		delete(h.pending, kr.SeqID)
+		h.mu.Unlock()
+	}()
+
+	dataJson, err := json.Marshal(kr)
+	if err != nil {
+		return KuratorResponse{}, err
+	}
+
+	agent.writeMu.Lock()
+	err = agent.conn.WriteMessage(websocket.TextMessage, dataJson)
+	agent.writeMu.Unlock()
+	if err != nil {
+		return KuratorResponse{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, kuratorRequestTimeout)
+	defer cancel()
+
+	select {
+	case kresp := <-ch:
+		if kresp.Error != "" {
+			return kresp, errors.New(kresp.Error)
+		}
+		return kresp, nil
+	case <-ctx.Done():
+		return KuratorResponse{}, errorT("agent_no_reply", ctx.Err())
+	}
+}
+
+// offlineAuthUser answers /auth_user without the platform. Any credentials are
+// accepted and mapped to a single local author account.
+func offlineAuthUser(c echo.Context) error {
+	const offlineUserID = 1
+
+	token := "offline-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	kv, err := NewKeyValueStore("tokens")
+	if err != nil {
+		return err
+	}
+	err = kv.Set(token, strconv.FormatInt(offlineUserID, 10))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusAccepted, map[string]interface{}{"token": token, "user_id": offlineUserID})
+}
+
+func offlineUserInfo(c echo.Context) error {
+	return c.JSON(http.StatusOK, UserInfo{Name: "Offline author", Email: "author@localhost"})
+}
+
+// runKuratorRequest sends kr to a locally connected agent (always in offline
+// mode) and relays it through the platform otherwise.
+func runKuratorRequest(ctx context.Context, hub *agentHub, offline bool, kr KuratorRequest, devtoken string) (KuratorResponse, error) {
+	if offline || (hub != nil && hub.HasAgent(kr.UserID)) {
+		kr.IsDev = true
+		return hub.Run(ctx, kr)
+	}
+
+	pc := newPlatformClient(devtoken)
+	pc.HTTPClient.Timeout = kuratorRequestTimeout
+	result, err := pc.RunKuratorRequest(ctx, kr)
+	if err != nil {
+		return KuratorResponse{}, err
+	}
+
+	kresp := KuratorResponse{}
+	err = json.Unmarshal(result, &kresp)
+	if err != nil {
+		return kresp, errorT("agent_unexpected_response", err)
+	}
+	return kresp, nil
+}
@@ -0,0 +1,281 @@
+package lib
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	// tasks/N.<lang>.yaml and base.<lang>.yaml hold translations of tasks/N.yaml and base.yaml
+	taskOverlayName = regexp.MustCompile(`^(\d+)\.([a-z]{2,3}(?:-[a-z0-9]+)?)\.yaml$`)
+	baseOverlayName = regexp.MustCompile(`^base\.([a-z]{2,3}(?:-[a-z0-9]+)?)\.yaml$`)
+
+	// Only these keys are taken from translations, handlers and ids always come from the base file
+	translatableKeys = []string{"courseTitle", "authorName", "authorPosition", "taskTitle", "intro", "content", "text", "title", "question", "answer"}
+)
+
+// applyTranslation overlays translated strings from overlay onto base, both
+// decoded by yaml.v2. Lists of items with an id are matched by id, other lists
+// by index. visit, if set, is called for every translatable string of base.
+func applyTranslation(base, overlay interface{}, path, key string, visit func(path string, translated bool)) interface{} {
+	switch b := base.(type) {
+	case map[interface{}]interface{}:
+		o, _ := overlay.(map[interface{}]interface{})
+		keys := make([]string, 0, len(b))
+		for k := range b {
+			keys = append(keys, fmt.Sprint(k))
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			childPath := k
+			if path != "" {
+				childPath = path + "." + k
+			}
+			var ov interface{}
+			if o != nil {
+				ov = o[k]
+			}
+			b[k] = applyTranslation(b[k], ov, childPath, k, visit)
+		}
+		return b
+	case []interface{}:
+		o, _ := overlay.([]interface{})
+		for n, item := range b {
+			itemPath := fmt.Sprintf("%s[%d]", path, n)
+			var ov interface{}
+			if id := translationItemID(item); id != "" {
+				itemPath = fmt.Sprintf("%s[%s]", path, id)
+				for _, candidate := range o {
+					if translationItemID(candidate) == id {
+						ov = candidate
+					}
+				}
+			} else if n < len(o) {
+				ov = o[n]
+			}
+			b[n] = applyTranslation(item, ov, itemPath, key, visit)
+		}
+		return b
+	case string:
+		if !StringSliceContains(translatableKeys, key) || strings.TrimSpace(b) == "" {
+			return base
+		}
+		translation, ok := overlay.(string)
+		translated := ok && strings.TrimSpace(translation) != ""
+		if visit != nil {
+			visit(path, translated)
+		}
+		if translated {
+			return translation
+		}
+	}
+	return base
+}
+
+func translationItemID(item interface{}) string {
+	m, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return ""
+	}
+	id, _ := m["id"].(string)
+	return id
+}
+
+func taskOverlayPath(courseDir string, number int, lang string) string {
+	return filepath.Join(courseDir, "tasks", fmt.Sprintf("%d.%s.yaml", number, lang))
+}
+
+func baseOverlayPath(courseDir, lang string) string {
+	return filepath.Join(courseDir, fmt.Sprintf("base.%s.yaml", lang))
+}
+
+// courseLanguages lists languages that have at least one translation file.
+func courseLanguages(courseDir string) ([]string, error) {
+	seen := map[string]bool{}
+
+	files, err := ioutil.ReadDir(courseDir)
+	if err != nil {
+		return nil, err
+	}
+	taskFiles, err := ioutil.ReadDir(filepath.Join(courseDir, "tasks"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for _, f := range files {
+		if m := baseOverlayName.FindStringSubmatch(f.Name()); m != nil {
+			seen[m[1]] = true
+		}
+	}
+	for _, f := range taskFiles {
+		if m := taskOverlayName.FindStringSubmatch(f.Name()); m != nil {
+			seen[m[2]] = true
+		}
+	}
+
+	var langs []string
+	for lang := range seen {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs, nil
+}
+
+// parseAcceptLanguage returns language tags ordered by preference. Each
+// regional tag is followed by its primary language, e.g. ru-ru, ru, en.
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, _ = strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+			}
+		}
+		tags = append(tags, weighted{tag: tag, q: q})
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	var langs []string
+	for _, t := range tags {
+		for _, lang := range []string{t.tag, strings.Split(t.tag, "-")[0]} {
+			if !StringSliceContains(langs, lang) {
+				langs = append(langs, lang)
+			}
+		}
+	}
+	return langs
+}
+
+// requestLangs returns the languages for a dev server request: the --lang
+// option if set, otherwise the browser Accept-Language preferences.
+func requestLangs(c echo.Context) []string {
+	if lang, _ := c.Get("lang").(string); lang != "" {
+		return []string{lang}
+	}
+	return parseAcceptLanguage(c.Request().Header.Get("Accept-Language"))
+}
+
+// TranslationStatus counts translated strings of a course file in one language.
+type TranslationStatus struct {
+	Lang       string   `json:"lang"`
+	File       string   `json:"file"`
+	Translated int      `json:"translated"`
+	Total      int      `json:"total"`
+	Missing    []string `json:"missing"`
+}
+
+func translationStatus(courseDir, file, overlayPath, lang string) (TranslationStatus, error) {
+	status := TranslationStatus{Lang: lang, File: overlayPath, Missing: []string{}}
+
+	base, err := readCourseFile(courseDir, file)
+	if err != nil {
+		return status, err
+	}
+	var overlay interface{}
+	if _, err := os.Stat(overlayPath); err == nil {
+		overlay, err = readCourseFile(courseDir, overlayPath)
+		if err != nil {
+			return status, err
+		}
+	}
+
+	applyTranslation(base, overlay, "", "", func(path string, translated bool) {
+		status.Total++
+		if translated {
+			status.Translated++
+		} else {
+			status.Missing = append(status.Missing, path)
+		}
+	})
+	return status, nil
+}
+
+func I18nStatusCLI(c *cli.Context) error {
+	courseDir := c.String("course_name")
+	format := c.String("output")
+	err := checkOutputFormat(format)
+	if err != nil {
+		return err
+	}
+
+	langs := c.StringSlice("lang")
+	if len(langs) == 0 {
+		langs, err = courseLanguages(courseDir)
+		if err != nil {
+			return err
+		}
+	}
+	if len(langs) == 0 {
+		fmt.Println("Course has no translations. Add tasks/N.<lang>.yaml or base.<lang>.yaml files to translate it")
+		return nil
+	}
+
+	tasks, err := loadCourseTasks(courseDir)
+	if err != nil {
+		return err
+	}
+
+	statuses := []TranslationStatus{}
+	for _, lang := range langs {
+		status, err := translationStatus(courseDir, filepath.Join(courseDir, "base.yaml"), baseOverlayPath(courseDir, lang), lang)
+		if err != nil {
+			return err
+		}
+		statuses = append(statuses, status)
+
+		for _, task := range tasks {
+			status, err := translationStatus(courseDir, task.File, taskOverlayPath(courseDir, task.Number, lang), lang)
+			if err != nil {
+				return err
+			}
+			statuses = append(statuses, status)
+		}
+	}
+
+	var rows [][]string
+	for _, status := range statuses {
+		percent := 100
+		if status.Total > 0 {
+			percent = status.Translated * 100 / status.Total
+		}
+		rows = append(rows, []string{
+			status.Lang,
+			status.File,
+			fmt.Sprintf("%d/%d", status.Translated, status.Total),
+			fmt.Sprintf("%d%%", percent),
+		})
+	}
+	err = renderOutput(format, []string{"Lang", "File", "Translated", "%"}, rows, statuses)
+	if err != nil {
+		return err
+	}
+
+	if format == "table" {
+		for _, status := range statuses {
+			for _, path := range status.Missing {
+				fmt.Printf("%s: untranslated %s\n", status.File, path)
+			}
+		}
+	}
+	return nil
+}
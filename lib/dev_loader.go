@@ -0,0 +1,540 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// includeKey replaces a map or list item with the content of another course
+// file, e.g. `- $include: shared/faq-k8s.yaml`. Paths are relative to the course folder.
+const includeKey = "$include"
+
+// courseVar matches {{ .vars.name }} placeholders, other {{ }} are left as is.
+var courseVar = regexp.MustCompile(`\{\{\s*\.vars\.([A-Za-z0-9_.]+)\s*\}\}`)
+
+// courseTaskFile is a task of a course folder with its file number.
+type courseTaskFile struct {
+	Number int
+	File   string
+	Info   TaskInfo
+}
+
+// readCourseFile decodes a course YAML file with its includes resolved.
+// stack holds the files being included to detect cycles.
+func readCourseFile(courseDir, file string, stack ...string) (interface{}, error) {
+	dat, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	err = yaml.Unmarshal(dat, &value)
+	if err != nil {
+		return nil, newYAMLError(file, err)
+	}
+	return expandIncludes(courseDir, file, value, append(stack, file))
+}
+
+func includePath(m map[interface{}]interface{}) (string, bool) {
+	target, ok := m[includeKey]
+	if !ok {
+		return "", false
+	}
+	path, _ := target.(string)
+	return path, true
+}
+
+// expandIncludes replaces $include maps of value. A map with other keys is
+// merged over the included map. A list item including a list is spliced into the parent list.
+func expandIncludes(courseDir, file string, value interface{}, stack []string) (interface{}, error) {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		if target, ok := includePath(v); ok {
+			included, err := readInclude(courseDir, file, target, stack)
+			if err != nil {
+				return nil, err
+			}
+			if len(v) == 1 {
+				return included, nil
+			}
+			base, ok := included.(map[interface{}]interface{})
+			if !ok {
+				return nil, &YAMLError{File: file, Message: fmt.Sprintf("%s %s must contain a map to be merged with other keys", includeKey, target)}
+			}
+			delete(v, includeKey)
+			for key, item := range v {
+				base[key] = item
+			}
+			v = base
+		}
+		for key, item := range v {
+			expanded, err := expandIncludes(courseDir, file, item, stack)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = expanded
+		}
+		return v, nil
+	case []interface{}:
+		var items []interface{}
+		for _, item := range v {
+			expanded, err := expandIncludes(courseDir, file, item, stack)
+			if err != nil {
+				return nil, err
+			}
+			if m, ok := item.(map[interface{}]interface{}); ok && len(m) == 1 {
+				if list, ok := expanded.([]interface{}); ok {
+					if _, ok := includePath(m); ok {
+						items = append(items, list...)
+						continue
+					}
+				}
+			}
+			items = append(items, expanded)
+		}
+		return items, nil
+	}
+	return value, nil
+}
+
+func readInclude(courseDir, file, target string, stack []string) (interface{}, error) {
+	if target == "" {
+		return nil, &YAMLError{File: file, Message: fmt.Sprintf("%s must be a file path", includeKey)}
+	}
+	path := filepath.Join(courseDir, filepath.FromSlash(target))
+	rel, err := filepath.Rel(courseDir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, &YAMLError{File: file, Message: fmt.Sprintf("%s %s is outside of the course folder", includeKey, target)}
+	}
+	if StringSliceContains(stack, path) {
+		return nil, &YAMLError{File: file, Message: fmt.Sprintf("%s cycle: %s -> %s", includeKey, strings.Join(stack, " -> "), path)}
+	}
+
+	value, err := readCourseFile(courseDir, path, stack...)
+	if os.IsNotExist(err) {
+		return nil, &YAMLError{File: file, Message: fmt.Sprintf("%s %s: file not found", includeKey, target)}
+	}
+	return value, err
+}
+
+// courseVars returns the vars map of base.yaml used in {{ .vars.name }} placeholders.
+func courseVars(courseDir string) (map[string]interface{}, error) {
+	base, err := readCourseFile(courseDir, filepath.Join(courseDir, "base.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	m, _ := base.(map[interface{}]interface{})
+	vars, _ := jsonCompatible(m["vars"]).(map[string]interface{})
+	return vars, nil
+}
+
+func lookupVar(vars map[string]interface{}, name string) (interface{}, bool) {
+	var value interface{} = vars
+	for _, part := range strings.Split(name, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		value, ok = m[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// interpolateVars replaces {{ .vars.name }} in all strings of value.
+func interpolateVars(file string, value interface{}, vars map[string]interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		for key, item := range v {
+			interpolated, err := interpolateVars(file, item, vars)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = interpolated
+		}
+	case []interface{}:
+		for n, item := range v {
+			interpolated, err := interpolateVars(file, item, vars)
+			if err != nil {
+				return nil, err
+			}
+			v[n] = interpolated
+		}
+	case string:
+		var missing []string
+		result := courseVar.ReplaceAllStringFunc(v, func(placeholder string) string {
+			name := courseVar.FindStringSubmatch(placeholder)[1]
+			varValue, ok := lookupVar(vars, name)
+			if !ok {
+				missing = append(missing, name)
+				return placeholder
+			}
+			return fmt.Sprint(varValue)
+		})
+		if len(missing) > 0 {
+			return nil, &YAMLError{File: file, Message: fmt.Sprintf("unknown variable %s, define it in vars of base.yaml", strings.Join(missing, ", "))}
+		}
+		return result, nil
+	}
+	return value, nil
+}
+
+// renderCourseFile expands includes of a course file, applies the first
+// existing translation of langs and interpolates course variables.
+// overlayFile returns the translation path for a language.
+func renderCourseFile(courseDir, file string, langs []string, overlayFile func(lang string) string) ([]byte, error) {
+	value, err := readCourseFile(courseDir, file)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, lang := range langs {
+		overlayPath := overlayFile(lang)
+		if _, err := os.Stat(overlayPath); os.IsNotExist(err) {
+			continue
+		}
+		overlay, err := readCourseFile(courseDir, overlayPath)
+		if err != nil {
+			return nil, err
+		}
+		value = applyTranslation(value, overlay, "", "", nil)
+		break
+	}
+
+	vars, err := courseVars(courseDir)
+	if err != nil {
+		return nil, err
+	}
+	value, err = interpolateVars(file, value, vars)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(value)
+}
+
+// decodeCourseFile decodes a file rendered by renderCourseFile into out. The
+// rendered text has its own line numbers, so errors are mapped back to the
+// line of the author's file or of the included file the value came from.
+func decodeCourseFile(courseDir, file string, rendered []byte, out interface{}) error {
+	err := yaml.Unmarshal(rendered, out)
+	if err == nil {
+		return nil
+	}
+	var te *yaml.TypeError
+	if !errors.As(err, &te) {
+		return newYAMLError(file, err)
+	}
+
+	renderedDoc := newYAMLLocator(rendered)
+	ye := &YAMLError{File: file}
+	var messages []string
+	for _, e := range te.Errors {
+		origin, line := file, 0
+		if m := yamlErrorLine.FindStringSubmatch(e); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			e = strings.TrimSpace(strings.Replace(e, m[0], "", 1))
+			origin, line = locateOrigin(courseDir, file, renderedDoc.pathAt(n))
+		}
+		if len(messages) == 0 {
+			ye.File, ye.Line = origin, line
+			messages = append(messages, e)
+			continue
+		}
+		messages = append(messages, (&YAMLError{File: origin, Line: line, Message: e}).Error())
+	}
+	ye.Message = strings.Join(messages, "\n")
+	return ye
+}
+
+// parseYAMLNode returns the root node of a YAML file, or nil when it can't be read.
+func parseYAMLNode(file string) *yamlv3.Node {
+	dat, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	var doc yamlv3.Node
+	if yamlv3.Unmarshal(dat, &doc) != nil || len(doc.Content) == 0 {
+		return nil
+	}
+	return doc.Content[0]
+}
+
+// nodeInclude returns the file and root node included by a $include map node.
+func nodeInclude(courseDir string, node *yamlv3.Node) (string, *yamlv3.Node, bool) {
+	if node.Kind != yamlv3.MappingNode {
+		return "", nil, false
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == includeKey {
+			file := filepath.Join(courseDir, filepath.FromSlash(node.Content[i+1].Value))
+			root := parseYAMLNode(file)
+			return file, root, root != nil
+		}
+	}
+	return "", nil, false
+}
+
+// expandedLen returns the number of items of a sequence node after list includes are spliced in.
+func expandedLen(courseDir string, seq *yamlv3.Node) int {
+	count := 0
+	for _, item := range seq.Content {
+		if _, included, ok := nodeInclude(courseDir, item); ok && len(item.Content) == 2 && included.Kind == yamlv3.SequenceNode {
+			count += expandedLen(courseDir, included)
+			continue
+		}
+		count++
+	}
+	return count
+}
+
+// locateOrigin follows path of a rendered course file through its includes
+// the way expandIncludes builds it and returns the file and line of the value,
+// or of its closest existing parent.
+func locateOrigin(courseDir, file string, path []interface{}) (string, int) {
+	node := parseYAMLNode(file)
+	if node == nil {
+		return file, 0
+	}
+
+	for _, p := range path {
+		var next *yamlv3.Node
+		nextFile := file
+		switch key := p.(type) {
+		case string:
+			for next == nil && node.Kind == yamlv3.MappingNode {
+				for i := 0; i+1 < len(node.Content); i += 2 {
+					if node.Content[i].Value == key {
+						next = node.Content[i+1]
+						if next.Kind == yamlv3.ScalarNode {
+							next = node.Content[i]
+						}
+						break
+					}
+				}
+				// Keys missing from the map come from the included map it is merged over
+				includedFile, included, ok := nodeInclude(courseDir, node)
+				if next != nil || !ok {
+					break
+				}
+				node, file, nextFile = included, includedFile, includedFile
+			}
+		case int:
+			for next == nil && node.Kind == yamlv3.SequenceNode {
+				var spliced *yamlv3.Node
+				for _, item := range node.Content {
+					if includedFile, included, ok := nodeInclude(courseDir, item); ok && len(item.Content) == 2 && included.Kind == yamlv3.SequenceNode {
+						if count := expandedLen(courseDir, included); key >= count {
+							key -= count
+							continue
+						}
+						spliced, nextFile = included, includedFile
+						break
+					}
+					if key == 0 {
+						next = item
+						break
+					}
+					key--
+				}
+				if spliced == nil {
+					break
+				}
+				node, file = spliced, nextFile
+			}
+		}
+		if next == nil {
+			break
+		}
+		// A whole map replaced by an include continues in the included file
+		for {
+			includedFile, included, ok := nodeInclude(courseDir, next)
+			if !ok || len(next.Content) != 2 {
+				break
+			}
+			next, nextFile = included, includedFile
+		}
+		node, file = next, nextFile
+	}
+
+	return file, node.Line
+}
+
+// loadCourseInfo reads base.yaml of the course in courseDir translated to
+// the first of langs that has a base.<lang>.yaml.
+func loadCourseInfo(courseDir string, langs ...string) (CourseInfo, error) {
+	ci := CourseInfo{}
+	file := filepath.Join(courseDir, "base.yaml")
+	dat, err := renderCourseFile(courseDir, file, langs, func(lang string) string { return baseOverlayPath(courseDir, lang) })
+	if err != nil {
+		return ci, err
+	}
+	err = decodeCourseFile(courseDir, file, dat, &ci)
+	return ci, err
+}
+
+func courseTaskPath(courseDir string, number int) string {
+	return filepath.Join(courseDir, "tasks", fmt.Sprintf("%d.yaml", number))
+}
+
+// loadCourseTask reads tasks/N.yaml translated to the first of langs that has a tasks/N.<lang>.yaml.
+func loadCourseTask(courseDir string, number int, langs ...string) (TaskInfo, error) {
+	ti := TaskInfo{}
+	file := courseTaskPath(courseDir, number)
+	dat, err := renderCourseFile(courseDir, file, langs, func(lang string) string { return taskOverlayPath(courseDir, number, lang) })
+	if err != nil {
+		return ti, err
+	}
+	err = decodeCourseFile(courseDir, file, dat, &ti)
+	return ti, err
+}
+
+// courseTaskNumbers returns N of the tasks/N.yaml files in course order.
+func courseTaskNumbers(courseDir string) ([]int, error) {
+	files, err := ioutil.ReadDir(filepath.Join(courseDir, "tasks"))
+	if err != nil {
+		return nil, err
+	}
+
+	var numbers []int
+	for _, f := range files {
+		m := taskFileName.FindStringSubmatch(f.Name())
+		if f.IsDir() || m == nil {
+			continue
+		}
+		number, _ := strconv.Atoi(m[1])
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+	return numbers, nil
+}
+
+// loadCourseTasks reads tasks/N.yaml files of the course ordered by N.
+// Translations (tasks/N.<lang>.yaml) are applied, not listed.
+func loadCourseTasks(courseDir string, langs ...string) ([]courseTaskFile, error) {
+	numbers, err := courseTaskNumbers(courseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []courseTaskFile
+	for _, number := range numbers {
+		ti, err := loadCourseTask(courseDir, number, langs...)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, courseTaskFile{Number: number, File: courseTaskPath(courseDir, number), Info: ti})
+	}
+	return tasks, nil
+}
+
+// resolveCourseTask finds a task by its taskID or by its 1-based position in
+// the course, the number shown in the task list and used in dev server URLs.
+// Position and file number differ when task files have gaps.
+func resolveCourseTask(courseDir, ref string, langs ...string) (courseTaskFile, error) {
+	numbers, err := courseTaskNumbers(courseDir)
+	if err != nil {
+		return courseTaskFile{}, err
+	}
+
+	if position, err := strconv.Atoi(ref); err == nil {
+		if position < 1 || position > len(numbers) {
+			return courseTaskFile{}, fmt.Errorf("course %s has no task %d", courseDir, position)
+		}
+		number := numbers[position-1]
+		ti, err := loadCourseTask(courseDir, number, langs...)
+		return courseTaskFile{Number: number, File: courseTaskPath(courseDir, number), Info: ti}, err
+	}
+
+	for _, number := range numbers {
+		ti, err := loadCourseTask(courseDir, number, langs...)
+		if err != nil {
+			return courseTaskFile{}, err
+		}
+		if ti.TaskID == ref {
+			return courseTaskFile{Number: number, File: courseTaskPath(courseDir, number), Info: ti}, nil
+		}
+	}
+	return courseTaskFile{}, fmt.Errorf("course %s has no task with taskID %s", courseDir, ref)
+}
+
+// taskRef returns the reference of a dev server request: the taskID if set, the position otherwise.
+func taskRef(taskID string, taskNumber int) string {
+	if taskID != "" {
+		return taskID
+	}
+	return strconv.Itoa(taskNumber)
+}
+
+// taskFileWarnings reports task files the loader ignores and gaps in numbering.
+func taskFileWarnings(courseDir string) ([]string, error) {
+	files, err := ioutil.ReadDir(filepath.Join(courseDir, "tasks"))
+	if err != nil {
+		return nil, err
+	}
+	numbers, err := courseTaskNumbers(courseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var warnings []string
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || filepath.Ext(name) != ".yaml" || taskFileName.MatchString(name) || taskOverlayName.MatchString(name) {
+			continue
+		}
+		number, err := strconv.Atoi(strings.TrimSuffix(name, ".yaml"))
+		switch {
+		case err != nil:
+			warnings = append(warnings, fmt.Sprintf("tasks/%s is ignored: task files are named N.yaml", name))
+		case IntSliceContains(numbers, number):
+			warnings = append(warnings, fmt.Sprintf("tasks/%s is ignored: it duplicates task number %d of tasks/%d.yaml", name, number, number))
+		default:
+			warnings = append(warnings, fmt.Sprintf("tasks/%s is ignored: rename it to %d.yaml", name, number))
+		}
+	}
+	for n, number := range numbers {
+		if number != n+1 {
+			warnings = append(warnings, fmt.Sprintf("task numbers have a gap: tasks/%d.yaml is task %d of the course. Run `kurator dev reorder` to renumber", number, n+1))
+			break
+		}
+	}
+	return warnings, nil
+}
+
+func RenderCourseCLI(c *cli.Context) error {
+	courseDir := c.String("course_name")
+	var langs []string
+	if lang := c.String("lang"); lang != "" {
+		langs = []string{lang}
+	}
+
+	file := filepath.Join(courseDir, "base.yaml")
+	overlayFile := func(lang string) string { return baseOverlayPath(courseDir, lang) }
+	if ref := c.String("task"); ref != "" {
+		task, err := resolveCourseTask(courseDir, ref)
+		if err != nil {
+			return err
+		}
+		file = task.File
+		overlayFile = func(lang string) string { return taskOverlayPath(courseDir, task.Number, lang) }
+	}
+
+	dat, err := renderCourseFile(courseDir, file, langs, overlayFile)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("# %s\n%s", file, dat)
+	return nil
+}
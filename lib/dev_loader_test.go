@@ -0,0 +1,70 @@
+package lib
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeCourseFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	courseDir := filepath.Join(t.TempDir(), "course")
+	for name, content := range files {
+		file := filepath.Join(courseDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return courseDir
+}
+
+func TestLoadCourseTaskErrorLines(t *testing.T) {
+	courseDir := writeCourseFiles(t, map[string]string{
+		"base.yaml": "courseTitle: T\nvars:\n  version: \"1.29\"\n",
+		"tasks/1.yaml": `# Task with a comment so the rendered lines differ
+taskTitle: "Kubernetes {{ .vars.version }}"
+
+taskID: first
+
+goals:
+  - $include: shared/goals.yaml
+  - id: second
+    statusHandler: check_second
+    contents:
+      - kind: text
+
+        content: [not, a, string]
+`,
+		"shared/goals.yaml": "- id: first\n  statusHandler: check_first\n",
+		"shared/bad.yaml":   "- id: bad\n\n  statusHandler: {not: a string}\n",
+		"tasks/2.yaml":      "taskTitle: Second\ntaskID: second\ngoals:\n  - id: g\n  - $include: shared/bad.yaml\n",
+	})
+
+	tests := []struct {
+		number int
+		file   string
+		line   int
+	}{
+		{1, "tasks/1.yaml", 13},
+		{2, "shared/bad.yaml", 3},
+	}
+	for _, tt := range tests {
+		_, err := loadCourseTask(courseDir, tt.number)
+		var ye *YAMLError
+		if !errors.As(err, &ye) {
+			t.Fatalf("task %d: err = %v, want YAMLError", tt.number, err)
+		}
+		if ye.File != filepath.Join(courseDir, tt.file) || ye.Line != tt.line {
+			t.Errorf("task %d: error at %s:%d, want %s:%d", tt.number, ye.File, ye.Line, tt.file, tt.line)
+		}
+		if strings.Contains(ye.Message, "line ") {
+			t.Errorf("task %d: message %q still has a line of the rendered text", tt.number, ye.Message)
+		}
+	}
+}
@@ -0,0 +1,476 @@
+package lib
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Markdown convention shared by `kurator dev import-md` and `kurator dev export --format markdown-src`:
+//
+//	# Task title                  starts a task
+//	```kurator:task               taskID, isFree and dependsOn of the task
+//	## Step title                 starts a goal
+//	```kurator:goal               id, statusHandler and runHandler of the goal
+//	```kurator:<kind>             content block of a kind, e.g. button, server or quiz, with its fields
+//	## FAQ                        ### Question headings followed by answers
+//
+// Other text becomes text blocks of the goal, text before the first goal is the task intro.
+const (
+	directivePrefix = "kurator:"
+	faqHeading      = "FAQ"
+)
+
+var (
+	markdownHeading = regexp.MustCompile(`^(#{1,3})\s+(.*?)\s*#*\s*$`)
+	markdownFence   = regexp.MustCompile("^ {0,3}(```+|~~~+)\\s*(\\S*)")
+	slugChars       = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+func slug(text string) string {
+	return strings.Trim(slugChars.ReplaceAllString(strings.ToLower(text), "-"), "-")
+}
+
+func writeDirective(b *strings.Builder, kind string, value interface{}) error {
+	dat, err := yaml.Marshal(value)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(b, "```%s%s\n%s```\n\n", directivePrefix, kind, dat)
+	return nil
+}
+
+// needsTextDirective tells if a text block can't be written as plain
+// Markdown because importing it back would split or merge it.
+func needsTextDirective(text string, afterText bool) bool {
+	if afterText {
+		return true
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if m := markdownHeading.FindStringSubmatch(line); m != nil && len(m[1]) < 3 {
+			return true
+		}
+		if m := markdownFence.FindStringSubmatch(line); m != nil && strings.HasPrefix(m[2], directivePrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// importableTaskMarkdown writes a task in the convention read by `kurator dev import-md`.
+func (e *courseExporter) importableTaskMarkdown(task courseTaskFile) (string, error) {
+	ti := task.Info
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n", ti.TaskTitle)
+	header := yaml.MapSlice{{Key: "taskID", Value: ti.TaskID}, {Key: "isFree", Value: ti.IsFree}}
+	if len(ti.DependsOn) > 0 {
+		header = append(header, yaml.MapItem{Key: "dependsOn", Value: ti.DependsOn})
+	}
+	err := writeDirective(&b, "task", header)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(ti.Intro) != "" {
+		fmt.Fprintf(&b, "%s\n\n", strings.TrimSpace(e.media(ti.Intro)))
+	}
+
+	for n, goal := range ti.Goals {
+		fmt.Fprintf(&b, "## Step %d\n\n", n+1)
+		goalFields := yaml.MapSlice{{Key: "id", Value: goal.ID}}
+		if goal.StatusHandler != "" {
+			goalFields = append(goalFields, yaml.MapItem{Key: "statusHandler", Value: goal.StatusHandler})
+		}
+		if goal.RunHandler != "" {
+			goalFields = append(goalFields, yaml.MapItem{Key: "runHandler", Value: goal.RunHandler})
+		}
+		err = writeDirective(&b, "goal", goalFields)
+		if err != nil {
+			return "", err
+		}
+
+		afterText := false
+		for _, content := range goal.Contents {
+			if text, ok := content.Block.(*TextBlock); ok && content.KuratorRequest.IsEmpty() && !needsTextDirective(text.Content, afterText) {
+				fmt.Fprintf(&b, "%s\n\n", strings.TrimSpace(e.media(text.Content)))
+				afterText = true
+				continue
+			}
+			afterText = false
+
+			fields, err := content.MarshalYAML()
+			if err != nil {
+				return "", err
+			}
+			dat, err := yaml.Marshal(fields.(yaml.MapSlice)[1:])
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(&b, "```%s%s\n%s```\n\n", directivePrefix, content.Kind, e.media(string(dat)))
+		}
+	}
+
+	if len(ti.Faqs) > 0 {
+		fmt.Fprintf(&b, "## %s\n\n", faqHeading)
+		for _, faq := range ti.Faqs {
+			fmt.Fprintf(&b, "### %s\n\n%s\n\n", strings.Join(strings.Fields(faq.Question), " "), strings.TrimSpace(e.media(faq.Answer)))
+		}
+	}
+	return b.String(), nil
+}
+
+// markdownImporter builds tasks from a Markdown document line by line.
+type markdownImporter struct {
+	file  string
+	tasks []TaskInfo
+	// step headings of the goals of every task, used for default goal ids
+	steps [][]string
+	inFaq bool
+	prose []string
+}
+
+func (m *markdownImporter) errorf(line int, format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", m.file, line, fmt.Sprintf(format, args...))
+}
+
+func (m *markdownImporter) task() *TaskInfo {
+	if len(m.tasks) == 0 {
+		return nil
+	}
+	return &m.tasks[len(m.tasks)-1]
+}
+
+func (m *markdownImporter) goal() *Goal {
+	task := m.task()
+	if task == nil || m.inFaq || len(task.Goals) == 0 {
+		return nil
+	}
+	return &task.Goals[len(task.Goals)-1]
+}
+
+// flush puts the collected text into the FAQ answer, the goal or the task intro.
+func (m *markdownImporter) flush(line int) error {
+	lines := m.prose
+	m.prose = nil
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	task := m.task()
+	if len(lines) == 0 || task == nil {
+		return nil
+	}
+	text := strings.Join(lines, "\n") + "\n"
+
+	switch {
+	case m.inFaq:
+		if len(task.Faqs) == 0 {
+			return m.errorf(line, "text in %s must follow a ### question heading", faqHeading)
+		}
+		task.Faqs[len(task.Faqs)-1].Answer = text
+	case m.goal() != nil:
+		goal := m.goal()
+		goal.Contents = append(goal.Contents, Content{Kind: "text", Block: &TextBlock{Content: text}})
+	case task.Intro != "":
+		task.Intro += "\n" + text
+	default:
+		task.Intro = text
+	}
+	return nil
+}
+
+func (m *markdownImporter) heading(line int, raw string, level int, text string) error {
+	// Only task, step and FAQ headings split the text
+	if level == 3 && !m.inFaq {
+		if m.task() != nil {
+			m.prose = append(m.prose, raw)
+		}
+		return nil
+	}
+	err := m.flush(line)
+	if err != nil {
+		return err
+	}
+
+	if level == 1 {
+		m.tasks = append(m.tasks, TaskInfo{TaskTitle: text, DependsOn: []string{}, Goals: []Goal{}, Faqs: []Faq{}})
+		m.steps = append(m.steps, nil)
+		m.inFaq = false
+		return nil
+	}
+
+	task := m.task()
+	if task == nil {
+		return m.errorf(line, "heading %q comes before the first task, start tasks with # Title", text)
+	}
+	switch {
+	case level == 2 && strings.EqualFold(text, faqHeading):
+		m.inFaq = true
+	case level == 2:
+		m.inFaq = false
+		task.Goals = append(task.Goals, Goal{})
+		m.steps[len(m.steps)-1] = append(m.steps[len(m.steps)-1], text)
+	default:
+		task.Faqs = append(task.Faqs, Faq{Question: text})
+	}
+	return nil
+}
+
+func (m *markdownImporter) directive(line int, kind string, body []byte) error {
+	err := m.flush(line)
+	if err != nil {
+		return err
+	}
+
+	task := m.task()
+	if task == nil {
+		return m.errorf(line, "%s%s comes before the first task, start tasks with # Title", directivePrefix, kind)
+	}
+	switch kind {
+	case "task":
+		if len(task.Goals) > 0 || m.inFaq {
+			return m.errorf(line, "%stask must follow the task heading", directivePrefix)
+		}
+		err = yaml.Unmarshal(body, task)
+	case "goal":
+		goal := m.goal()
+		if goal == nil {
+			return m.errorf(line, "%sgoal must follow a ## step heading", directivePrefix)
+		}
+		err = yaml.Unmarshal(body, goal)
+	default:
+		goal := m.goal()
+		if goal == nil {
+			return m.errorf(line, "%s%s must be inside a ## step", directivePrefix, kind)
+		}
+		if newContentBlock(kind) == nil {
+			return m.errorf(line, "unknown content kind %q. Known kinds: %s", kind, strings.Join(knownContentKinds(), ", "))
+		}
+		var fields yaml.MapSlice
+		err = yaml.Unmarshal(body, &fields)
+		if err != nil {
+			break
+		}
+		var dat []byte
+		dat, err = yaml.Marshal(append(yaml.MapSlice{{Key: "kind", Value: kind}}, fields...))
+		if err != nil {
+			break
+		}
+		content := Content{}
+		err = yaml.Unmarshal(dat, &content)
+		if err != nil {
+			break
+		}
+		// A quiz without a correct answer can't be passed, e.g. one exported with --hide-answers
+		if quiz, ok := content.Block.(*QuizBlock); ok && !quiz.hasCorrectAnswer() {
+			return m.errorf(line, "%squiz %s has no answer with isCorrect: true", directivePrefix, quiz.ID)
+		}
+		goal.Contents = append(goal.Contents, content)
+	}
+	if err != nil {
+		return m.errorf(line, "%s%s: %v", directivePrefix, kind, err)
+	}
+	return nil
+}
+
+// parseCourseMarkdown converts a Markdown document to tasks. mediaPrefix
+// replaces media/ links written by the export, e.g. /media/<name>/.
+func parseCourseMarkdown(file, text, mediaPrefix string) ([]TaskInfo, error) {
+	text = strings.ReplaceAll(text, "](media/", "]("+mediaPrefix)
+	text = strings.ReplaceAll(text, `src="media/`, `src="`+mediaPrefix)
+
+	m := &markdownImporter{file: file}
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for n := 0; n < len(lines); n++ {
+		line := lines[n]
+
+		if fence := markdownFence.FindStringSubmatch(line); fence != nil {
+			// Collect the fenced block up to the closing fence
+			start := n
+			for n++; n < len(lines); n++ {
+				if closing := markdownFence.FindStringSubmatch(lines[n]); closing != nil && strings.HasPrefix(closing[1], fence[1]) && closing[2] == "" {
+					break
+				}
+			}
+			if n == len(lines) {
+				return nil, m.errorf(start+1, "code block is not closed")
+			}
+			if kind := strings.TrimPrefix(fence[2], directivePrefix); kind != fence[2] {
+				err := m.directive(start+1, kind, []byte(strings.Join(lines[start+1:n], "\n")))
+				if err != nil {
+					return nil, err
+				}
+			} else if m.task() != nil {
+				m.prose = append(m.prose, lines[start:n+1]...)
+			}
+			continue
+		}
+
+		if heading := markdownHeading.FindStringSubmatch(line); heading != nil {
+			err := m.heading(n+1, line, len(heading[1]), heading[2])
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Text before the first task, e.g. the export comment, is skipped
+		if m.task() != nil {
+			m.prose = append(m.prose, line)
+		}
+	}
+	err := m.flush(len(lines))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(m.tasks) == 0 {
+		return nil, fmt.Errorf("%s has no tasks, start tasks with # Title", file)
+	}
+	for n := range m.tasks {
+		task := &m.tasks[n]
+		if task.TaskID == "" {
+			task.TaskID = slug(task.TaskTitle)
+		}
+		if task.TaskID == "" {
+			task.TaskID = fmt.Sprintf("task-%d", n+1)
+		}
+		defaultGoalIDs(task, m.steps[n])
+	}
+	return m.tasks, nil
+}
+
+// defaultGoalIDs names goals without a kurator:goal id after the task and the step heading.
+func defaultGoalIDs(task *TaskInfo, steps []string) {
+	for n := range task.Goals {
+		if task.Goals[n].ID != "" {
+			continue
+		}
+		step := fmt.Sprintf("step-%d", n+1)
+		if n < len(steps) && slug(steps[n]) != "" {
+			step = slug(steps[n])
+		}
+		task.Goals[n].ID = task.TaskID + "-" + step
+	}
+}
+
+// usesIncludesOrVars reports whether a raw course file has $include or
+// {{ .vars.name }} placeholders, which a rewritten file would lose.
+func usesIncludesOrVars(dat []byte) bool {
+	if courseVar.Match(dat) {
+		return true
+	}
+	var walk func(node *yamlv3.Node) bool
+	walk = func(node *yamlv3.Node) bool {
+		for i, child := range node.Content {
+			if node.Kind == yamlv3.MappingNode && i%2 == 0 && child.Value == includeKey {
+				return true
+			}
+			if walk(child) {
+				return true
+			}
+		}
+		return false
+	}
+	root := &yamlv3.Node{}
+	if yamlv3.Unmarshal(dat, root) != nil {
+		return false
+	}
+	return walk(root)
+}
+
+func ImportMarkdownCLI(c *cli.Context) error {
+	courseDir := c.String("course_name")
+	if c.NArg() != 1 {
+		return fmt.Errorf("usage: kurator dev import-md --course_name <name> <file.md>")
+	}
+	file := c.Args().First()
+
+	if _, err := os.Stat(filepath.Join(courseDir, "base.yaml")); err != nil {
+		return fmt.Errorf("%s is not a course, create it with `kurator dev create-course` first", courseDir)
+	}
+	dat, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	name := filepath.Base(filepath.Clean(courseDir))
+	tasks, err := parseCourseMarkdown(file, string(dat), "/media/"+name+"/")
+	if err != nil {
+		return err
+	}
+
+	existing := map[string]int{}
+	next := 1
+	numbers, err := courseTaskNumbers(courseDir)
+	if err != nil {
+		return err
+	}
+	for _, number := range numbers {
+		ti, err := loadCourseTask(courseDir, number)
+		if err != nil {
+			return err
+		}
+		existing[ti.TaskID] = number
+		next = number + 1
+	}
+
+	// Check all files first so that a refused import changes nothing
+	for _, task := range tasks {
+		number, ok := existing[task.TaskID]
+		if !ok || c.Bool("force") {
+			continue
+		}
+		path := courseTaskPath(courseDir, number)
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if usesIncludesOrVars(raw) {
+			return fmt.Errorf("%s uses %s or vars, the import would replace them with their content. Add --force to overwrite it", path, includeKey)
+		}
+	}
+
+	_, schemaErr := os.Stat(filepath.Join(courseDir, schemaDir))
+	for _, task := range tasks {
+		number, ok := existing[task.TaskID]
+		action := "overwritten"
+		if !ok {
+			number, action = next, "created"
+			next++
+		}
+		path := courseTaskPath(courseDir, number)
+		fmt.Printf("%s: %s %s\n", path, action, task.TaskID)
+		if c.Bool("dry-run") {
+			continue
+		}
+
+		out, err := yaml.Marshal(task)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(path, out, 0644)
+		if err != nil {
+			return err
+		}
+		if schemaErr == nil {
+			err = addSchemaModeline(path, filepath.Join("..", schemaDir, taskSchemaFile))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if c.Bool("dry-run") {
+		fmt.Println("Dry run, no files changed")
+	}
+	return nil
+}
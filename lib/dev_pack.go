@@ -0,0 +1,386 @@
+package lib
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"gl.biggo.pro/devopstrain/kurator/lib/client"
+)
+
+const manifestFile = "manifest.json"
+
+var validCourseVersion = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z.+-]*$`)
+
+// BundleManifest describes the content of a course bundle.
+type BundleManifest struct {
+	Name        string            `json:"name"`
+	Version     string            `json:"version"`
+	CourseTitle string            `json:"courseTitle"`
+	Tasks       []BundleTask      `json:"tasks"`
+	Media       []BundleMediaFile `json:"media"`
+}
+
+type BundleTask struct {
+	Number    int    `json:"number"`
+	TaskID    string `json:"taskID"`
+	TaskTitle string `json:"taskTitle"`
+	File      string `json:"file"`
+	SHA256    string `json:"sha256"`
+}
+
+type BundleMediaFile struct {
+	File   string `json:"file"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+func sha256Hex(dat []byte) string {
+	sum := sha256.Sum256(dat)
+	return hex.EncodeToString(sum[:])
+}
+
+// bundleFiles returns course files to pack relative to courseDir: base.yaml,
+// task files, shared files for $include and media. Hidden files and folders are skipped.
+func bundleFiles(courseDir string) ([]string, error) {
+	files := []string{"base.yaml"}
+
+	for _, dir := range []string{"tasks", "shared", "media"} {
+		root := filepath.Join(courseDir, dir)
+		if _, err := os.Stat(root); os.IsNotExist(err) {
+			continue
+		}
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(info.Name(), ".") {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info.IsDir() {
+				return nil
+			}
+			rel, err := filepath.Rel(courseDir, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(rel))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(files[1:])
+	return files, nil
+}
+
+// buildManifest reads course files and describes them in a manifest.
+func buildManifest(courseDir, name, version string, files []string) (BundleManifest, error) {
+	manifest := BundleManifest{Name: name, Version: version, Tasks: []BundleTask{}, Media: []BundleMediaFile{}}
+
+	for _, file := range files {
+		dat, err := ioutil.ReadFile(filepath.Join(courseDir, file))
+		if err != nil {
+			return manifest, err
+		}
+
+		switch {
+		case file == "base.yaml":
+			ci, err := loadCourseInfo(courseDir)
+			if err != nil {
+				return manifest, err
+			}
+			manifest.CourseTitle = ci.CourseTitle
+		case strings.HasPrefix(file, "tasks/"):
+			m := taskFileName.FindStringSubmatch(filepath.Base(file))
+			if m == nil {
+				continue
+			}
+			number, _ := strconv.Atoi(m[1])
+			ti, err := loadCourseTask(courseDir, number)
+			if err != nil {
+				return manifest, err
+			}
+			manifest.Tasks = append(manifest.Tasks, BundleTask{
+				Number:    number,
+				TaskID:    ti.TaskID,
+				TaskTitle: ti.TaskTitle,
+				File:      file,
+				SHA256:    sha256Hex(dat),
+			})
+		case strings.HasPrefix(file, "media/"):
+			manifest.Media = append(manifest.Media, BundleMediaFile{File: file, Size: int64(len(dat)), SHA256: sha256Hex(dat)})
+		}
+	}
+
+	sort.Slice(manifest.Tasks, func(i, j int) bool { return manifest.Tasks[i].Number < manifest.Tasks[j].Number })
+	return manifest, nil
+}
+
+// writeBundle writes a gzipped tar with the manifest first and the course
+// files after it. Timestamps and owners are fixed so the same course always
+// produces the same checksum.
+func writeBundle(w io.Writer, courseDir string, manifest BundleManifest, files []string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	manifestJson, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	add := func(name string, dat []byte) error {
+		err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(dat)), Typeflag: tar.TypeReg, Format: tar.FormatPAX})
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(dat)
+		return err
+	}
+
+	err = add(manifestFile, manifestJson)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		dat, err := ioutil.ReadFile(filepath.Join(courseDir, file))
+		if err != nil {
+			return err
+		}
+		err = add(file, dat)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// readBundleManifest returns the manifest of a bundle built by PackCourseCLI.
+func readBundleManifest(bundle []byte) (BundleManifest, error) {
+	manifest := BundleManifest{}
+
+	gz, err := gzip.NewReader(bytes.NewReader(bundle))
+	if err != nil {
+		return manifest, fmt.Errorf("not a course bundle: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return manifest, fmt.Errorf("not a course bundle: %s is missing", manifestFile)
+		}
+		if err != nil {
+			return manifest, fmt.Errorf("not a course bundle: %w", err)
+		}
+		if header.Name != manifestFile {
+			continue
+		}
+		err = json.NewDecoder(tr).Decode(&manifest)
+		return manifest, err
+	}
+}
+
+// extractBundle writes the course files of a bundle to dir.
+func extractBundle(bundle []byte, dir string) error {
+	gz, err := gzip.NewReader(bytes.NewReader(bundle))
+	if err != nil {
+		return fmt.Errorf("not a course bundle: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("not a course bundle: %w", err)
+		}
+
+		name := path.Clean(header.Name)
+		if header.Typeflag != tar.TypeReg || name == manifestFile {
+			continue
+		}
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("bundle file %s is outside of the course folder", header.Name)
+		}
+
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		err = os.MkdirAll(filepath.Dir(file), 0755)
+		if err != nil {
+			return err
+		}
+		dat, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(file, dat, 0644)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func printProblems(problems []Problem) int {
+	errorsCount := 0
+	for _, p := range problems {
+		if p.Severity == SeverityError {
+			errorsCount++
+		}
+		fmt.Println(p)
+	}
+	return errorsCount
+}
+
+func PackCourseCLI(c *cli.Context) error {
+	courseDir := c.String("course_name")
+	name := filepath.Base(filepath.Clean(courseDir))
+
+	problems, err := ValidateCourse(courseDir)
+	if err != nil {
+		return err
+	}
+	if printProblems(problems) > 0 {
+		return cli.Exit("Course has errors, fix them before packing. Run `kurator dev validate` for details", 1)
+	}
+
+	version := c.String("version")
+	if version == "" {
+		ci, err := loadCourseInfo(courseDir)
+		if err != nil {
+			return err
+		}
+		version = ci.Version
+	}
+	if version == "" {
+		return fmt.Errorf("course version is not set. Add `version:` to base.yaml or use --version")
+	}
+	if !validCourseVersion.MatchString(version) {
+		return fmt.Errorf("invalid version %s. Use letters, digits, dots, dashes and plus", version)
+	}
+
+	files, err := bundleFiles(courseDir)
+	if err != nil {
+		return err
+	}
+	manifest, err := buildManifest(courseDir, name, version, files)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	err = writeBundle(&buf, courseDir, manifest, files)
+	if err != nil {
+		return err
+	}
+
+	output := c.String("output")
+	if output == "" {
+		output = fmt.Sprintf("%s-%s.tar.gz", name, version)
+	}
+	err = ioutil.WriteFile(output, buf.Bytes(), 0644)
+	if err != nil {
+		return err
+	}
+
+	checksum := sha256Hex(buf.Bytes())
+	err = ioutil.WriteFile(output+".sha256", []byte(fmt.Sprintf("%s  %s\n", checksum, filepath.Base(output))), 0644)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Course %s %s packed to %s: %d tasks, %d media files\n", name, version, output, len(manifest.Tasks), len(manifest.Media))
+	fmt.Printf("sha256: %s\n", checksum)
+	return nil
+}
+
+// loadBundle reads a bundle and checks it against the .sha256 file written
+// next to it by PackCourseCLI. The checksum file is optional.
+func loadBundle(bundlePath string) ([]byte, string, error) {
+	bundle, err := ioutil.ReadFile(bundlePath)
+	if err != nil {
+		return nil, "", err
+	}
+	checksum := sha256Hex(bundle)
+
+	expected, err := ioutil.ReadFile(bundlePath + ".sha256")
+	if err == nil {
+		fields := strings.Fields(string(expected))
+		if len(fields) == 0 || fields[0] != checksum {
+			return nil, "", fmt.Errorf("%s does not match %s.sha256, the bundle is corrupted", bundlePath, bundlePath)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, "", err
+	}
+	return bundle, checksum, nil
+}
+
+// publishBundle uploads the bundle at bundlePath to targetURL.
+func publishBundle(bundlePath string, draft bool) (BundleManifest, client.Course, error) {
+	bundle, checksum, err := loadBundle(bundlePath)
+	if err != nil {
+		return BundleManifest{}, client.Course{}, err
+	}
+
+	manifest, err := readBundleManifest(bundle)
+	if err != nil {
+		return manifest, client.Course{}, err
+	}
+
+	token, err := requireValidSession()
+	if err != nil {
+		return manifest, client.Course{}, err
+	}
+
+	course, err := newPlatformClient(token).PublishCourse(context.Background(), manifest.Name, manifest.Version, checksum, draft, bundle)
+	return manifest, course, err
+}
+
+func PublishCourseCLI(c *cli.Context) error {
+	bundlePath := c.Args().First()
+	if bundlePath == "" {
+		return fmt.Errorf("missing bundle path. Build it with `kurator dev pack`")
+	}
+	if c.String("api-url") != "" {
+		targetURL = strings.TrimSuffix(c.String("api-url"), "/")
+	}
+
+	manifest, course, err := publishBundle(bundlePath, c.Bool("draft"))
+	if err != nil {
+		return err
+	}
+
+	state := "published"
+	if course.IsDraft {
+		state = "uploaded as draft"
+	}
+	fmt.Printf("Course %s %s is %s\n", manifest.Name, manifest.Version, state)
+	if course.URL != "" {
+		fmt.Println(course.URL)
+	}
+	return nil
+}
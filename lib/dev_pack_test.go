@@ -0,0 +1,227 @@
+package lib
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+const testBaseYAML = `courseTitle: Test course
+authorName: Ann
+`
+
+const testTaskYAML = `taskTitle: First task
+taskID: first
+isFree: true
+intro: Intro text
+goals:
+  - id: goal1
+    statusHandler: check_goal1
+    contents:
+      - kind: text
+        content: Hello
+`
+
+func writeTestCourse(t *testing.T) string {
+	t.Helper()
+	courseDir := filepath.Join(t.TempDir(), "test-course")
+	files := map[string]string{
+		"base.yaml":         testBaseYAML,
+		"tasks/1.yaml":      testTaskYAML,
+		"media/logo.txt":    "logo",
+		"media/.hidden.txt": "skipped",
+	}
+	for name, content := range files {
+		file := filepath.Join(courseDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return courseDir
+}
+
+// runPack runs PackCourseCLI with the given flag values.
+func runPack(t *testing.T, courseDir, output string) {
+	t.Helper()
+	set := flag.NewFlagSet("pack", flag.ContinueOnError)
+	set.String("course_name", courseDir, "")
+	set.String("version", "1.0.0", "")
+	set.String("output", output, "")
+
+	err := PackCourseCLI(cli.NewContext(cli.NewApp(), set, nil))
+	if err != nil {
+		t.Fatalf("pack: %v", err)
+	}
+}
+
+func TestPackRoundTrip(t *testing.T) {
+	courseDir := writeTestCourse(t)
+	outDir := t.TempDir()
+	first := filepath.Join(outDir, "first.tar.gz")
+	second := filepath.Join(outDir, "second.tar.gz")
+
+	runPack(t, courseDir, first)
+	runPack(t, courseDir, second)
+
+	bundle, checksum, err := loadBundle(first)
+	if err != nil {
+		t.Fatalf("loadBundle: %v", err)
+	}
+	secondBundle, secondChecksum, err := loadBundle(second)
+	if err != nil {
+		t.Fatalf("loadBundle: %v", err)
+	}
+	if !bytes.Equal(bundle, secondBundle) || checksum != secondChecksum {
+		t.Errorf("packing the same course twice gave different bundles")
+	}
+
+	manifest, err := readBundleManifest(bundle)
+	if err != nil {
+		t.Fatalf("readBundleManifest: %v", err)
+	}
+	if manifest.Name != "test-course" || manifest.Version != "1.0.0" || manifest.CourseTitle != "Test course" {
+		t.Errorf("manifest = %+v", manifest)
+	}
+	if len(manifest.Tasks) != 1 || manifest.Tasks[0].TaskID != "first" || manifest.Tasks[0].File != "tasks/1.yaml" {
+		t.Errorf("manifest tasks = %+v", manifest.Tasks)
+	}
+	if len(manifest.Media) != 1 || manifest.Media[0].File != "media/logo.txt" || manifest.Media[0].Size != 4 {
+		t.Errorf("manifest media = %+v", manifest.Media)
+	}
+
+	extractDir := t.TempDir()
+	if err := extractBundle(bundle, extractDir); err != nil {
+		t.Fatalf("extractBundle: %v", err)
+	}
+	for _, name := range []string{"base.yaml", "tasks/1.yaml", "media/logo.txt"} {
+		want, _ := ioutil.ReadFile(filepath.Join(courseDir, name))
+		got, err := ioutil.ReadFile(filepath.Join(extractDir, name))
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("%s was not extracted as packed: %v", name, err)
+		}
+	}
+	for _, name := range []string{manifestFile, "media/.hidden.txt"} {
+		if _, err := os.Stat(filepath.Join(extractDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s should not be extracted", name)
+		}
+	}
+}
+
+func TestLoadBundleChecksumMismatch(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "course.tar.gz")
+	runPack(t, writeTestCourse(t), output)
+
+	err := ioutil.WriteFile(output+".sha256", []byte(strings.Repeat("0", 64)+"  course.tar.gz\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = loadBundle(output)
+	if err == nil || !strings.Contains(err.Error(), "corrupted") {
+		t.Errorf("err = %v, want checksum mismatch", err)
+	}
+
+	// Without a checksum file the bundle is accepted as is
+	os.Remove(output + ".sha256")
+	if _, _, err := loadBundle(output); err != nil {
+		t.Errorf("unexpected error without checksum file: %v", err)
+	}
+}
+
+func testBundle(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestExtractBundleRejectsTraversal(t *testing.T) {
+	for _, name := range []string{"../evil.yaml", "tasks/../../evil.yaml", "/etc/evil.yaml", ".."} {
+		dir := filepath.Join(t.TempDir(), "course")
+		err := extractBundle(testBundle(t, map[string]string{name: "evil"}), dir)
+		if err == nil || !strings.Contains(err.Error(), "outside of the course folder") {
+			t.Errorf("%s: err = %v, want rejection", name, err)
+		}
+		if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "evil.yaml")); !os.IsNotExist(err) {
+			t.Errorf("%s: file was written outside of the course folder", name)
+		}
+	}
+}
+
+func TestPublishBundle(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "test-course-1.0.0.tar.gz")
+	runPack(t, writeTestCourse(t), output)
+	bundle, checksum, err := loadBundle(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var uploaded []byte
+	var query, token, version, sum string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/user_info":
+			w.Write([]byte(`{"name":"Ann"}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/course/test-course/bundle":
+			uploaded, _ = ioutil.ReadAll(r.Body)
+			query = r.URL.Query().Get("draft")
+			token = r.Header.Get("Token")
+			version = r.Header.Get("X-Course-Version")
+			sum = r.Header.Get("X-Course-Checksum")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"short_name":"test-course","is_draft":` + query + `}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	oldTargetURL := targetURL
+	targetURL = server.URL
+	defer func() { targetURL = oldTargetURL }()
+	t.Setenv(tokenEnvVar, "ci-token")
+
+	for _, draft := range []bool{true, false} {
+		manifest, course, err := publishBundle(output, draft)
+		if err != nil {
+			t.Fatalf("draft=%t: %v", draft, err)
+		}
+		if manifest.Name != "test-course" || manifest.Version != "1.0.0" {
+			t.Errorf("draft=%t: manifest = %+v", draft, manifest)
+		}
+		if !bytes.Equal(uploaded, bundle) {
+			t.Errorf("draft=%t: uploaded body differs from the bundle", draft)
+		}
+		if token != "ci-token" {
+			t.Errorf("draft=%t: Token header = %q", draft, token)
+		}
+		if version != "1.0.0" || sum != "sha256:"+checksum {
+			t.Errorf("draft=%t: version = %q, checksum = %q", draft, version, sum)
+		}
+		if course.IsDraft != draft {
+			t.Errorf("draft=%t: query draft=%s gave IsDraft = %t", draft, query, course.IsDraft)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type RequestQuizAnswer struct {
+	CourseName string `json:"courseName"`
+	TaskNumber int    `json:"taskNumber"` // position of the task in the course, used if taskID is empty
+	TaskID     string `json:"taskID"`
+	QuizID     string `json:"quizID"`
+	Answers    []int  `json:"answers"` // indexes of the selected answers
+}
+
+type QuizAttempt struct {
+	Answers   []int     `json:"answers"`
+	IsCorrect bool      `json:"isCorrect"`
+	Time      time.Time `json:"time"`
+}
+
+type QuizResult struct {
+	QuizID        string `json:"quizID"`
+	IsCorrect     bool   `json:"isCorrect"`
+	Attempts      int    `json:"attempts"`
+	GoalCompleted bool   `json:"goalCompleted"`
+}
+
+// Quizzes returns the quiz blocks of the goal.
+func (g Goal) Quizzes() []*QuizBlock {
+	var quizzes []*QuizBlock
+	for _, content := range g.Contents {
+		if quiz, ok := content.Block.(*QuizBlock); ok {
+			quizzes = append(quizzes, quiz)
+		}
+	}
+	return quizzes
+}
+
+// stripQuizAnswers hides correct answers before the task is sent to the browser.
+func stripQuizAnswers(ti *TaskInfo) {
+	for _, goal := range ti.Goals {
+		for _, quiz := range goal.Quizzes() {
+			for n := range quiz.Answers {
+				quiz.Answers[n].IsCorrect = false
+			}
+		}
+	}
+}
+
+// Grade checks that exactly the correct answers are selected.
+func (b *QuizBlock) Grade(selected []int) (bool, error) {
+	if len(selected) == 0 {
+		return false, errorT("quiz_no_answer")
+	}
+	if len(selected) > 1 && !b.AllowMultiple {
+		return false, errorT("quiz_single_answer")
+	}
+
+	seen := map[int]bool{}
+	for _, n := range selected {
+		if n < 0 || n >= len(b.Answers) {
+			return false, errorT("quiz_unknown_answer", n)
+		}
+		seen[n] = true
+	}
+
+	for n, answer := range b.Answers {
+		if answer.IsCorrect != seen[n] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func quizAttemptsKey(courseName, quizID string, userID int64) string {
+	return fmt.Sprintf("quiz-attempts-%s-%s-%d", courseName, quizID, userID)
+}
+
+// recordQuizAttempt appends the attempt to the user's history and returns the number of attempts.
+func recordQuizAttempt(courseName, quizID string, userID int64, attempt QuizAttempt) (int, error) {
+	kv, err := NewKeyValueStore("data")
+	if err != nil {
+		return 0, err
+	}
+
+	key := quizAttemptsKey(courseName, quizID, userID)
+	var attempts []QuizAttempt
+	resultJSON, err := kv.Get(key)
+	if err == nil {
+		err = json.Unmarshal([]byte(resultJSON), &attempts)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	attempts = append(attempts, attempt)
+	dataJson, err := json.Marshal(attempts)
+	if err != nil {
+		return 0, err
+	}
+	return len(attempts), kv.Set(key, string(dataJson))
+}
+
+// AnswerQuiz grades a quiz submission against the task YAML, records the
+// attempt and completes the goal when the quiz was its last condition.
+func AnswerQuiz(c echo.Context) error {
+	req := new(RequestQuizAnswer)
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+
+	langs := requestLangs(c)
+	task, err := resolveCourseTask(req.CourseName, taskRef(req.TaskID, req.TaskNumber))
+	if err != nil {
+		return err
+	}
+	ti := task.Info
+
+	userID := devUserID(c)
+	completedTasks, err := loadCompletedTasks(req.CourseName, userID)
+	if err != nil {
+		return err
+	}
+	if reason := taskLockReason(ti.IsFree, ti.DependsOn, completedTasks, simulatePaid(c), langs); reason != "" {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": T(langs, "task_locked", reason)})
+	}
+
+	var quiz *QuizBlock
+	var goal Goal
+	for _, g := range ti.Goals {
+		for _, q := range g.Quizzes() {
+			if q.ID == req.QuizID {
+				quiz, goal = q, g
+			}
+		}
+	}
+	if quiz == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": T(langs, "quiz_not_found", req.QuizID)})
+	}
+
+	isCorrect, err := quiz.Grade(req.Answers)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": localizeError(langs, err)})
+	}
+
+	progressMu.Lock()
+	defer progressMu.Unlock()
+
+	answers := append([]int{}, req.Answers...)
+	sort.Ints(answers)
+	attempts, err := recordQuizAttempt(req.CourseName, quiz.ID, userID, QuizAttempt{Answers: answers, IsCorrect: isCorrect, Time: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	if isCorrect {
+		err = addToCompletionList(passedQuizzesKey(req.CourseName, userID), quiz.ID)
+		if err != nil {
+			return err
+		}
+		err = updateCompletedGoals(req.CourseName, userID, ti)
+		if err != nil {
+			return err
+		}
+	}
+
+	completedGoals, err := loadCompletedGoals(req.CourseName, userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, QuizResult{
+		QuizID:        quiz.ID,
+		IsCorrect:     isCorrect,
+		Attempts:      attempts,
+		GoalCompleted: StringSliceContains(completedGoals, goal.ID),
+	})
+}
@@ -0,0 +1,241 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	watchInterval     = 500 * time.Millisecond
+	eventsKeepAlive   = 30 * time.Second
+	reloadScriptName  = "dev_reload.js"
+	reloadScriptTag   = `<script src="/` + reloadScriptName + `"></script>`
+	reloadEventReload = "reload"
+	reloadEventError  = "yaml-error"
+)
+
+var yamlErrorLine = regexp.MustCompile(`line (\d+):`)
+
+// YAMLError points to the course file and line that failed to parse.
+type YAMLError struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+func (e *YAMLError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.File, e.Message)
+}
+
+// newYAMLError extracts the line number from yaml.v2 error messages.
+func newYAMLError(file string, err error) *YAMLError {
+	message := strings.TrimPrefix(err.Error(), "yaml: ")
+	ye := &YAMLError{File: file, Message: message}
+	if m := yamlErrorLine.FindStringSubmatch(message); m != nil {
+		ye.Line, _ = strconv.Atoi(m[1])
+		// The line is reported by Error() with the file name
+		ye.Message = strings.TrimSpace(strings.Replace(message, m[0], "", 1))
+	}
+	return ye
+}
+
+// checkCourseYAML parses a course file with the type it is loaded into by the dev server.
+func checkCourseYAML(courseDir, file string) error {
+	rel, _ := filepath.Rel(courseDir, file)
+	if rel == "base.yaml" {
+		_, err := loadCourseInfo(courseDir)
+		return err
+	}
+	if m := taskFileName.FindStringSubmatch(filepath.Base(rel)); m != nil && filepath.Dir(rel) == "tasks" {
+		number, _ := strconv.Atoi(m[1])
+		_, err := loadCourseTask(courseDir, number)
+		return err
+	}
+	_, err := readCourseFile(courseDir, file)
+	return err
+}
+
+type reloadEvent struct {
+	Name string
+	Data string
+}
+
+// reloadBroker watches the course directory and notifies browsers through
+// server-sent events when files change or fail to parse.
+type reloadBroker struct {
+	courseDir string
+
+	mu          sync.Mutex
+	subscribers map[chan reloadEvent]struct{}
+	lastError   *YAMLError
+}
+
+func newReloadBroker(courseDir string) *reloadBroker {
+	return &reloadBroker{
+		courseDir:   courseDir,
+		subscribers: map[chan reloadEvent]struct{}{},
+	}
+}
+
+func (b *reloadBroker) publish(ev reloadEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			// Slow browser tab, it will catch up on the next change
+		}
+	}
+}
+
+func (b *reloadBroker) snapshot() map[string]string {
+	state := map[string]string{}
+	filepath.Walk(b.courseDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if path != b.courseDir && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		state[path] = fmt.Sprintf("%d-%d", info.ModTime().UnixNano(), info.Size())
+		return nil
+	})
+	return state
+}
+
+// Watch polls the course directory until stop is closed.
+func (b *reloadBroker) Watch(stop <-chan struct{}) {
+	previous := b.snapshot()
+	b.check(previous)
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		current := b.snapshot()
+		var changed []string
+		for path, stamp := range current {
+			if previous[path] != stamp {
+				changed = append(changed, path)
+			}
+		}
+		for path := range previous {
+			if _, ok := current[path]; !ok {
+				changed = append(changed, path)
+			}
+		}
+		previous = current
+		if len(changed) == 0 {
+			continue
+		}
+
+		sort.Strings(changed)
+		log.Printf("Course files changed: %s", strings.Join(changed, ", "))
+		if b.check(current) {
+			dataJson, _ := json.Marshal(map[string][]string{"files": changed})
+			b.publish(reloadEvent{Name: reloadEventReload, Data: string(dataJson)})
+		}
+	}
+}
+
+// check parses all YAML files of the course, publishes the first error and
+// reports whether the course is valid.
+func (b *reloadBroker) check(files map[string]string) bool {
+	var paths []string
+	for path := range files {
+		if filepath.Ext(path) == ".yaml" || filepath.Ext(path) == ".yml" {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	var yamlErr *YAMLError
+	for _, path := range paths {
+		err := checkCourseYAML(b.courseDir, path)
+		if ye, ok := err.(*YAMLError); ok {
+			yamlErr = ye
+			break
+		}
+	}
+
+	b.mu.Lock()
+	b.lastError = yamlErr
+	b.mu.Unlock()
+
+	if yamlErr != nil {
+		log.Printf("YAML error: %s", yamlErr)
+		dataJson, _ := json.Marshal(yamlErr)
+		b.publish(reloadEvent{Name: reloadEventError, Data: string(dataJson)})
+		return false
+	}
+	return true
+}
+
+// ServeEvents streams reload and error events to the browser.
+func (b *reloadBroker) ServeEvents(c echo.Context) error {
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, "text/event-stream")
+	w.Header().Set(echo.HeaderCacheControl, "no-cache")
+	w.Header().Set(echo.HeaderConnection, "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	ch := make(chan reloadEvent, 8)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	lastError := b.lastError
+	b.mu.Unlock()
+
+	defer func() {
+		b.mu.Lock()
+		delete(b.subscribers, ch)
+		b.mu.Unlock()
+	}()
+
+	// A page opened while the course is broken gets the overlay right away
+	if lastError != nil {
+		dataJson, _ := json.Marshal(lastError)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", reloadEventError, dataJson)
+	}
+	w.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case ev := <-ch:
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Name, ev.Data)
+		}
+		w.Flush()
+	}
+}
@@ -0,0 +1,317 @@
+package lib
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// reorderTask is a task at its place in the new course order.
+type reorderTask struct {
+	OldNumber int    // 0 for an inserted task
+	Source    string // file of an inserted task
+	TaskID    string
+	DependsOn []string
+	relinked  bool
+}
+
+// findReorderTask returns the index of the task with taskID or 1-based position ref.
+func findReorderTask(order []reorderTask, ref string) int {
+	if position, err := strconv.Atoi(ref); err == nil {
+		if position >= 1 && position <= len(order) {
+			return position - 1
+		}
+		return -1
+	}
+	for n, task := range order {
+		if task.TaskID == ref {
+			return n
+		}
+	}
+	return -1
+}
+
+// relinkDependsOn keeps chains of tasks that depend on the previous task:
+// a task that depended only on the task before it depends on its new
+// predecessor after the reorder. A task inserted into a chain joins it.
+func relinkDependsOn(order []reorderTask, oldPrev map[string]string) {
+	chained := func(task reorderTask) bool {
+		return task.OldNumber != 0 && len(task.DependsOn) == 1 && task.DependsOn[0] == oldPrev[task.TaskID]
+	}
+
+	for n := range order {
+		task := &order[n]
+		if task.OldNumber == 0 {
+			if len(task.DependsOn) == 0 && n > 0 && n+1 < len(order) && chained(order[n+1]) {
+				task.DependsOn = []string{order[n-1].TaskID}
+				task.relinked = true
+			}
+			continue
+		}
+		if !chained(*task) {
+			continue
+		}
+		var deps []string
+		if n > 0 {
+			deps = []string{order[n-1].TaskID}
+		}
+		if len(deps) != 1 || deps[0] != task.DependsOn[0] {
+			task.DependsOn = deps
+			task.relinked = true
+		}
+	}
+}
+
+// taskFilesOf returns tasks/N.yaml and its translations.
+func taskFilesOf(courseDir string, number int) ([]string, error) {
+	files, err := ioutil.ReadDir(filepath.Join(courseDir, "tasks"))
+	if err != nil {
+		return nil, err
+	}
+	names := []string{fmt.Sprintf("%d.yaml", number)}
+	for _, f := range files {
+		if m := taskOverlayName.FindStringSubmatch(f.Name()); m != nil && m[1] == strconv.Itoa(number) {
+			names = append(names, f.Name())
+		}
+	}
+	return names, nil
+}
+
+// renumberTaskFiles renames task files with their translations to their new
+// numbers. Files are moved to temporary names first so numbers can be swapped.
+func renumberTaskFiles(courseDir string, order []reorderTask) error {
+	dir := filepath.Join(courseDir, "tasks")
+	type rename struct{ tmp, dest string }
+	var renames []rename
+
+	for n, task := range order {
+		if task.OldNumber == 0 || task.OldNumber == n+1 {
+			continue
+		}
+		names, err := taskFilesOf(courseDir, task.OldNumber)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			tmp := filepath.Join(dir, ".reorder-"+name)
+			err = os.Rename(filepath.Join(dir, name), tmp)
+			if err != nil {
+				return err
+			}
+			dest := fmt.Sprintf("%d%s", n+1, name[len(strconv.Itoa(task.OldNumber)):])
+			renames = append(renames, rename{tmp: tmp, dest: filepath.Join(dir, dest)})
+		}
+	}
+
+	for _, r := range renames {
+		err := os.Rename(r.tmp, r.dest)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lastLine returns the last line of node and its children.
+func lastLine(node *yamlv3.Node) int {
+	line := node.Line
+	for _, child := range node.Content {
+		if l := lastLine(child); l > line {
+			line = l
+		}
+	}
+	return line
+}
+
+// setDependsOn replaces the dependsOn lines of a task file. Other lines are
+// kept as they are, re-encoding the whole file would reformat texts.
+func setDependsOn(file string, deps []string) error {
+	dat, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var doc yamlv3.Node
+	err = yamlv3.Unmarshal(dat, &doc)
+	if err != nil {
+		return newYAMLError(file, err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yamlv3.MappingNode {
+		return fmt.Errorf("%s: task must be a map", file)
+	}
+
+	if deps == nil {
+		deps = []string{}
+	}
+	line, err := yaml.Marshal(struct {
+		DependsOn []string `yaml:"dependsOn,flow"`
+	}{deps})
+	if err != nil {
+		return err
+	}
+
+	lines := strings.SplitAfter(string(dat), "\n")
+	if last := len(lines) - 1; lines[last] == "" {
+		lines = lines[:last]
+	} else {
+		lines[last] += "\n"
+	}
+
+	root := doc.Content[0]
+	start, end := -1, -1
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key := root.Content[i]
+		switch key.Value {
+		case "dependsOn":
+			start, end = key.Line-1, lastLine(root.Content[i+1])
+		case "taskID":
+			if start < 0 {
+				start, end = key.Line, key.Line
+			}
+		}
+	}
+	if start < 0 {
+		start, end = len(lines), len(lines)
+	}
+
+	result := append([]string{}, lines[:start]...)
+	result = append(result, string(line))
+	result = append(result, lines[end:]...)
+	return ioutil.WriteFile(file, []byte(strings.Join(result, "")), 0644)
+}
+
+func ReorderCourseCLI(c *cli.Context) error {
+	courseDir := c.String("course_name")
+	move := c.String("move")
+	insert := c.String("insert")
+	if move != "" && insert != "" {
+		return fmt.Errorf("use either --move or --insert")
+	}
+
+	tasks, err := loadCourseTasks(courseDir)
+	if err != nil {
+		return err
+	}
+	warnings, err := taskFileWarnings(courseDir)
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		fmt.Println("warning:", warning)
+	}
+
+	var order []reorderTask
+	oldPrev := map[string]string{}
+	for n, task := range tasks {
+		order = append(order, reorderTask{OldNumber: task.Number, TaskID: task.Info.TaskID, DependsOn: task.Info.DependsOn})
+		if n > 0 {
+			oldPrev[task.Info.TaskID] = tasks[n-1].Info.TaskID
+		}
+	}
+
+	to := c.Int("to")
+	switch {
+	case move != "":
+		from := findReorderTask(order, move)
+		if from < 0 {
+			return fmt.Errorf("course %s has no task %s", courseDir, move)
+		}
+		if to < 1 || to > len(order) {
+			return fmt.Errorf("--to must be a position from 1 to %d", len(order))
+		}
+		task := order[from]
+		order = append(order[:from], order[from+1:]...)
+		order = append(order[:to-1], append([]reorderTask{task}, order[to-1:]...)...)
+	case insert != "":
+		dat, err := ioutil.ReadFile(insert)
+		if err != nil {
+			return err
+		}
+		ti := TaskInfo{}
+		err = yaml.Unmarshal(dat, &ti)
+		if err != nil {
+			return newYAMLError(insert, err)
+		}
+		if ti.TaskID == "" {
+			return fmt.Errorf("%s: taskID is empty", insert)
+		}
+		if findReorderTask(order, ti.TaskID) >= 0 {
+			return fmt.Errorf("%s: taskID %s is already used in the course", insert, ti.TaskID)
+		}
+		if !c.IsSet("to") {
+			to = len(order) + 1
+		}
+		if to < 1 || to > len(order)+1 {
+			return fmt.Errorf("--to must be a position from 1 to %d", len(order)+1)
+		}
+		task := reorderTask{Source: insert, TaskID: ti.TaskID, DependsOn: ti.DependsOn}
+		order = append(order[:to-1], append([]reorderTask{task}, order[to-1:]...)...)
+	}
+	relinkDependsOn(order, oldPrev)
+
+	changes := 0
+	positions := map[string]int{}
+	for n, task := range order {
+		positions[task.TaskID] = n + 1
+		switch {
+		case task.OldNumber == 0:
+			fmt.Printf("%s -> tasks/%d.yaml\n", task.Source, n+1)
+		case task.OldNumber != n+1:
+			fmt.Printf("tasks/%d.yaml -> tasks/%d.yaml\n", task.OldNumber, n+1)
+		default:
+			continue
+		}
+		changes++
+	}
+	for n, task := range order {
+		if task.relinked {
+			fmt.Printf("tasks/%d.yaml: dependsOn %v\n", n+1, task.DependsOn)
+			changes++
+		}
+	}
+	for n, task := range order {
+		for _, dep := range task.DependsOn {
+			if positions[dep] > n+1 {
+				fmt.Printf("warning: task %s depends on %s which comes after it\n", task.TaskID, dep)
+			}
+		}
+	}
+
+	if changes == 0 {
+		fmt.Printf("Tasks of %s are already numbered 1..%d\n", courseDir, len(order))
+		return nil
+	}
+	if c.Bool("dry-run") {
+		fmt.Println("Dry run, no files changed")
+		return nil
+	}
+
+	err = renumberTaskFiles(courseDir, order)
+	if err != nil {
+		return err
+	}
+	for n, task := range order {
+		file := courseTaskPath(courseDir, n+1)
+		if task.OldNumber == 0 {
+			err = copyFile(task.Source, file)
+			if err != nil {
+				return err
+			}
+		}
+		if task.relinked {
+			err = setDependsOn(file, task.DependsOn)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Printf("Course %s has %d tasks numbered 1..%d\n", courseDir, len(order), len(order))
+	return nil
+}
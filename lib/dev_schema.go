@@ -0,0 +1,301 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	schemaDir          = ".schema"
+	courseSchemaFile   = "base.schema.json"
+	taskSchemaFile     = "task.schema.json"
+	schemaDraft        = "http://json-schema.org/draft-07/schema#"
+	yamlLanguageServer = "# yaml-language-server: $schema="
+)
+
+// JSONSchema is the subset of JSON Schema draft-07 used for course files.
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Const                string                 `json:"const,omitempty"`
+	OneOf                []*JSONSchema          `json:"oneOf,omitempty"`
+}
+
+// Schema details that can't be expressed with struct tags, keyed by YAML path.
+var (
+	schemaDescriptions = map[string]string{
+		"courseTitle":                        "Course title shown in the course list",
+		"courseSource":                       "Link to the course sources",
+		"version":                            "Course version used by `kurator dev pack`",
+		"baseServerHandlerURL":               "URL of the course handler, proxied by the platform",
+		"vars":                               "Course variables used in task files as {{ .vars.name }}",
+		"taskID":                             "Unique id of the task, used by dependsOn",
+		"isFree":                             "Task is available without a paid subscription",
+		"dependsOn":                          "Task ids that must be completed before this task is unlocked",
+		"goals.id":                           "Unique id of the goal in the course",
+		"goals.statusHandler":                "Handler method that checks if the goal is completed",
+		"goals.runHandler":                   "Handler method called on button click",
+		"goals.contents.kind":                "Content block kind",
+		"goals.contents.sourceHandler":       "Handler method that returns the content of a server block",
+		"goals.contents.disableOnClick":      "Seconds to disable the button after click, -1 disables it forever",
+		"goals.contents.kuratorRequest":      "Request sent to the student's kurator agent when the handler runs",
+		"goals.contents.kuratorRequest.type": "command runs payload on the student machine, contains checks files",
+	}
+	schemaEnums = map[string][]string{
+		"goals.contents.kind":                knownContentKinds(),
+		"goals.contents.kuratorRequest.type": knownKuratorRequest,
+	}
+	schemaRequired = map[string][]string{
+		"":                       {"courseTitle", "taskTitle", "taskID", "goals"},
+		"goals":                  {"id"},
+		"goals.contents":         {"kind"},
+		"goals.contents.tabs":    {"title", "content"},
+		"goals.contents.answers": {"text"},
+		"faqs":                   {"question", "answer"},
+	}
+)
+
+// CourseSchema returns the JSON Schema of base.yaml.
+func CourseSchema() *JSONSchema {
+	s := schemaFor(reflect.TypeOf(CourseInfo{}), "")
+	s.Schema = schemaDraft
+	s.Title = "kurator course base.yaml"
+	return s
+}
+
+// TaskSchema returns the JSON Schema of tasks/N.yaml.
+func TaskSchema() *JSONSchema {
+	s := schemaFor(reflect.TypeOf(TaskInfo{}), "")
+	s.Schema = schemaDraft
+	s.Title = "kurator course task"
+	return s
+}
+
+// schemaFor builds the schema of t from its yaml tags. Fields without a yaml
+// tag are filled in by kurator at runtime and are not part of the files.
+func schemaFor(t reflect.Type, path string) *JSONSchema {
+	if t == reflect.TypeOf(Content{}) {
+		return contentSchema(path)
+	}
+
+	s := &JSONSchema{Description: schemaDescriptions[path], Enum: schemaEnums[path]}
+
+	switch t.Kind() {
+	case reflect.String:
+		s.Type = "string"
+	case reflect.Bool:
+		s.Type = "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s.Type = "integer"
+	case reflect.Float32, reflect.Float64:
+		s.Type = "number"
+	case reflect.Slice, reflect.Array:
+		s.Type = "array"
+		s.Items = schemaFor(t.Elem(), path)
+		s.Items.Description = ""
+		s.Items.Enum = nil
+		if len(s.Enum) > 0 {
+			s.Items.Enum, s.Enum = s.Enum, nil
+		}
+		if s.Items.Type == "object" && s.Items.Properties != nil {
+			s.Items = &JSONSchema{OneOf: []*JSONSchema{s.Items, includeSchema()}}
+		} else if s.Items.OneOf != nil {
+			s.Items.OneOf = append(s.Items.OneOf, includeSchema())
+		}
+	case reflect.Map:
+		s.Type = "object"
+	case reflect.Struct:
+		closed := false
+		s.Type = "object"
+		s.Properties = map[string]*JSONSchema{}
+		s.AdditionalProperties = &closed
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			fieldPath := name
+			if path != "" {
+				fieldPath = path + "." + name
+			}
+			s.Properties[name] = schemaFor(field.Type, fieldPath)
+		}
+		for _, name := range schemaRequired[path] {
+			if _, ok := s.Properties[name]; ok {
+				s.Required = append(s.Required, name)
+			}
+		}
+	}
+
+	return s
+}
+
+// includeSchema allows a list item to be replaced with items of another file.
+func includeSchema() *JSONSchema {
+	closed := false
+	return &JSONSchema{
+		Title:                includeKey,
+		Type:                 "object",
+		Properties:           map[string]*JSONSchema{includeKey: {Description: "Course file with the items to insert, e.g. shared/faq.yaml", Type: "string"}},
+		Required:             []string{includeKey},
+		AdditionalProperties: &closed,
+	}
+}
+
+// contentSchema allows one of the registered content kinds with the fields of that kind.
+func contentSchema(path string) *JSONSchema {
+	s := &JSONSchema{Type: "object"}
+	for _, kind := range contentKinds {
+		variant := schemaFor(reflect.TypeOf(kind.New()).Elem(), path)
+		variant.Title = kind.Name
+		variant.Properties["kind"] = &JSONSchema{Description: schemaDescriptions[path+".kind"], Type: "string", Const: kind.Name}
+		variant.Properties["kuratorRequest"] = schemaFor(reflect.TypeOf(KuratorRequestSpec{}), path+".kuratorRequest")
+		variant.Required = append([]string{"kind"}, variant.Required...)
+		s.OneOf = append(s.OneOf, variant)
+	}
+	return s
+}
+
+func writeSchemaFile(path string, s *JSONSchema) error {
+	dataJson, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(dataJson, '\n'), 0644)
+}
+
+// writeCourseSchemas stores both schemas in the .schema folder of the course.
+func writeCourseSchemas(courseDir string) error {
+	dir := filepath.Join(courseDir, schemaDir)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	err = writeSchemaFile(filepath.Join(dir, courseSchemaFile), CourseSchema())
+	if err != nil {
+		return err
+	}
+	return writeSchemaFile(filepath.Join(dir, taskSchemaFile), TaskSchema())
+}
+
+// addSchemaModeline makes editors with the YAML language server validate file against schemaPath.
+func addSchemaModeline(file, schemaPath string) error {
+	dat, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(string(dat), yamlLanguageServer) {
+		return nil
+	}
+
+	modeline := yamlLanguageServer + filepath.ToSlash(schemaPath) + "\n"
+	return ioutil.WriteFile(file, append([]byte(modeline), dat...), 0644)
+}
+
+// removeSchemaModeline drops the modeline added by addSchemaModeline.
+func removeSchemaModeline(file string) error {
+	dat, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(string(dat), yamlLanguageServer) {
+		return nil
+	}
+
+	if n := strings.IndexByte(string(dat), '\n'); n >= 0 {
+		dat = dat[n+1:]
+	} else {
+		dat = nil
+	}
+	return ioutil.WriteFile(file, dat, 0644)
+}
+
+// addCourseModelines adds schema modelines to base.yaml and tasks/N.yaml.
+// Translations only have some of the fields required by the schemas, so they
+// get no modeline and the ones added by older versions are removed.
+func addCourseModelines(courseDir string) error {
+	err := addSchemaModeline(filepath.Join(courseDir, "base.yaml"), filepath.Join(schemaDir, courseSchemaFile))
+	if err != nil {
+		return err
+	}
+
+	numbers, err := courseTaskNumbers(courseDir)
+	if err != nil {
+		return err
+	}
+	for _, number := range numbers {
+		err = addSchemaModeline(courseTaskPath(courseDir, number), filepath.Join("..", schemaDir, taskSchemaFile))
+		if err != nil {
+			return err
+		}
+	}
+
+	taskOverlays, err := filepath.Glob(filepath.Join(courseDir, "tasks", "*.yaml"))
+	if err != nil {
+		return err
+	}
+	baseOverlays, err := filepath.Glob(filepath.Join(courseDir, "base.*.yaml"))
+	if err != nil {
+		return err
+	}
+	for _, file := range append(taskOverlays, baseOverlays...) {
+		name := filepath.Base(file)
+		if !taskOverlayName.MatchString(name) && !baseOverlayName.MatchString(name) {
+			continue
+		}
+		err = removeSchemaModeline(file)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func SchemaCLI(c *cli.Context) error {
+	courseName := c.String("course_name")
+	if courseName != "" {
+		err := writeCourseSchemas(courseName)
+		if err != nil {
+			return err
+		}
+		err = addCourseModelines(courseName)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Schemas are saved to %s\n", filepath.Join(courseName, schemaDir))
+		return nil
+	}
+
+	var s *JSONSchema
+	switch c.String("kind") {
+	case "course":
+		s = CourseSchema()
+	case "task":
+		s = TaskSchema()
+	default:
+		return fmt.Errorf("unknown schema kind %s. Use course or task", c.String("kind"))
+	}
+
+	dataJson, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(dataJson))
+	return nil
+}
@@ -0,0 +1,257 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// wordsPerMinute is the reading speed used to estimate reading time.
+const wordsPerMinute = 200
+
+var (
+	mediaRef     = regexp.MustCompile(`/media/([^/\s"'()<>\[\]]+)/([^\s"'()<>\[\]]+)`)
+	externalLink = regexp.MustCompile(`https?://[^\s"'()<>\[\]]+`)
+)
+
+type CourseStats struct {
+	Course          string         `json:"course"`
+	Tasks           int            `json:"tasks"`
+	FreeTasks       int            `json:"freeTasks"`
+	PaidTasks       int            `json:"paidTasks"`
+	Goals           int            `json:"goals"`
+	Handlers        int            `json:"handlers"`
+	Words           int            `json:"words"`
+	ReadingMinutes  int            `json:"readingMinutes"`
+	KuratorRequests map[string]int `json:"kuratorRequests"`
+	Commands        []StatsRef     `json:"commands"`
+	BrokenMedia     []StatsRef     `json:"brokenMedia"`
+	ExternalLinks   []StatsRef     `json:"externalLinks"`
+	TaskStats       []TaskStats    `json:"taskStats"`
+}
+
+type TaskStats struct {
+	Position        int    `json:"position"`
+	TaskID          string `json:"taskID"`
+	TaskTitle       string `json:"taskTitle"`
+	IsFree          bool   `json:"isFree"`
+	Goals           int    `json:"goals"`
+	Handlers        int    `json:"handlers"`
+	KuratorRequests int    `json:"kuratorRequests"`
+	Words           int    `json:"words"`
+	ReadingMinutes  int    `json:"readingMinutes"`
+}
+
+// StatsRef is a command, media file or link found in a task.
+type StatsRef struct {
+	TaskID string `json:"taskID"`
+	GoalID string `json:"goalID,omitempty"`
+	Value  string `json:"value"`
+}
+
+func readingMinutes(words int) int {
+	return (words + wordsPerMinute - 1) / wordsPerMinute
+}
+
+// readableTexts returns the texts of a content item a student reads.
+func readableTexts(content Content) []string {
+	switch block := content.Block.(type) {
+	case *TextBlock:
+		return []string{block.Content}
+	case *WhatHappenedBlock:
+		return []string{block.Content}
+	case *ButtonBlock:
+		return []string{block.Text}
+	case *TabsBlock:
+		var texts []string
+		for _, tab := range block.Tabs {
+			texts = append(texts, tab.Title, tab.Content)
+		}
+		return texts
+	case *QuizBlock:
+		texts := []string{block.Title}
+		for _, answer := range block.Answers {
+			texts = append(texts, answer.Text)
+		}
+		return texts
+	}
+	return nil
+}
+
+type courseStatsCollector struct {
+	name      string
+	courseDir string
+	stats     CourseStats
+	seen      map[string]bool
+}
+
+// addText counts words of text and collects its media references and links.
+func (s *courseStatsCollector) addText(ts *TaskStats, goalID, text string) {
+	ts.Words += len(strings.Fields(text))
+
+	for _, m := range mediaRef.FindAllStringSubmatch(text, -1) {
+		missing := m[1] != s.name
+		if !missing {
+			_, err := os.Stat(filepath.Join(s.courseDir, "media", m[2]))
+			missing = err != nil
+		}
+		if missing {
+			s.add("media", &s.stats.BrokenMedia, StatsRef{TaskID: ts.TaskID, GoalID: goalID, Value: m[0]})
+		}
+	}
+	for _, link := range externalLink.FindAllString(text, -1) {
+		s.add("link", &s.stats.ExternalLinks, StatsRef{TaskID: ts.TaskID, GoalID: goalID, Value: strings.TrimRight(link, ".,;:!?")})
+	}
+}
+
+// add appends ref to refs once per task.
+func (s *courseStatsCollector) add(kind string, refs *[]StatsRef, ref StatsRef) {
+	key := kind + " " + ref.TaskID + " " + ref.Value
+	if s.seen[key] {
+		return
+	}
+	s.seen[key] = true
+	*refs = append(*refs, ref)
+}
+
+func (s *courseStatsCollector) addTask(position int, ti TaskInfo, genTask GenTask) {
+	ts := TaskStats{Position: position, TaskID: ti.TaskID, TaskTitle: ti.TaskTitle, IsFree: ti.IsFree, Goals: len(ti.Goals)}
+
+	handlers := map[string]bool{}
+	for _, method := range genTask.Methods {
+		handlers[method.HandlerName] = true
+	}
+	ts.Handlers = len(handlers)
+
+	s.addText(&ts, "", ti.Intro)
+	for _, goal := range ti.Goals {
+		for _, content := range goal.Contents {
+			for _, text := range readableTexts(content) {
+				s.addText(&ts, goal.ID, text)
+			}
+
+			kr := content.KuratorRequest
+			if kr.IsEmpty() {
+				continue
+			}
+			ts.KuratorRequests++
+			s.stats.KuratorRequests[kr.Type]++
+			if kr.Type == "command" {
+				s.stats.Commands = append(s.stats.Commands, StatsRef{TaskID: ti.TaskID, GoalID: goal.ID, Value: strings.TrimSpace(kr.Payload)})
+			}
+		}
+	}
+	for _, faq := range ti.Faqs {
+		s.addText(&ts, "", faq.Question)
+		s.addText(&ts, "", faq.Answer)
+	}
+	ts.ReadingMinutes = readingMinutes(ts.Words)
+
+	s.stats.Tasks++
+	if ti.IsFree {
+		s.stats.FreeTasks++
+	} else {
+		s.stats.PaidTasks++
+	}
+	s.stats.Goals += ts.Goals
+	s.stats.Handlers += ts.Handlers
+	s.stats.Words += ts.Words
+	s.stats.TaskStats = append(s.stats.TaskStats, ts)
+}
+
+// collectCourseStats counts tasks, goals, handlers and kurator requests of the
+// course and audits its texts for broken media references and external links.
+func collectCourseStats(courseDir string) (CourseStats, error) {
+	name := filepath.Base(filepath.Clean(courseDir))
+	s := &courseStatsCollector{
+		name:      name,
+		courseDir: courseDir,
+		stats: CourseStats{
+			Course:          name,
+			KuratorRequests: map[string]int{},
+			Commands:        []StatsRef{},
+			BrokenMedia:     []StatsRef{},
+			ExternalLinks:   []StatsRef{},
+			TaskStats:       []TaskStats{},
+		},
+		seen: map[string]bool{},
+	}
+
+	taskInfos, err := parseYAMLFiles(courseDir)
+	if err != nil {
+		return s.stats, err
+	}
+	for n, genTask := range GenerateGenTasks(taskInfos) {
+		s.addTask(n+1, taskInfos[n], genTask)
+	}
+	s.stats.ReadingMinutes = readingMinutes(s.stats.Words)
+	return s.stats, nil
+}
+
+func printStatsRefs(title string, refs []StatsRef) {
+	if len(refs) == 0 {
+		return
+	}
+	fmt.Printf("\n%s:\n", title)
+	for _, ref := range refs {
+		place := ref.TaskID
+		if ref.GoalID != "" {
+			place += "/" + ref.GoalID
+		}
+		fmt.Printf("  %s: %s\n", place, strings.ReplaceAll(ref.Value, "\n", "\n    "))
+	}
+}
+
+func StatsCourseCLI(c *cli.Context) error {
+	format := c.String("output")
+	err := checkOutputFormat(format)
+	if err != nil {
+		return err
+	}
+
+	stats, err := collectCourseStats(c.String("course_name"))
+	if err != nil {
+		return err
+	}
+
+	var rows [][]string
+	for _, ts := range stats.TaskStats {
+		free := "paid"
+		if ts.IsFree {
+			free = "free"
+		}
+		rows = append(rows, []string{
+			strconv.Itoa(ts.Position), ts.TaskID, ts.TaskTitle, free,
+			strconv.Itoa(ts.Goals), strconv.Itoa(ts.Handlers), strconv.Itoa(ts.KuratorRequests),
+			strconv.Itoa(ts.Words), fmt.Sprintf("%d min", ts.ReadingMinutes),
+		})
+	}
+	err = renderOutput(format, []string{"#", "Task", "Title", "Access", "Goals", "Handlers", "Requests", "Words", "Reading"}, rows, stats)
+	if err != nil {
+		return err
+	}
+	if format != "table" {
+		return nil
+	}
+
+	fmt.Printf("%d task(s): %d free, %d paid. %d goal(s), %d handler(s), about %d min of reading\n",
+		stats.Tasks, stats.FreeTasks, stats.PaidTasks, stats.Goals, stats.Handlers, stats.ReadingMinutes)
+	if len(stats.KuratorRequests) > 0 {
+		var types []string
+		for kind, count := range stats.KuratorRequests {
+			types = append(types, fmt.Sprintf("%s: %d", kind, count))
+		}
+		sort.Strings(types)
+		fmt.Printf("kuratorRequest types: %s\n", strings.Join(types, ", "))
+	}
+	printStatsRefs("Commands run on student machines", stats.Commands)
+	printStatsRefs("Broken media references", stats.BrokenMedia)
+	printStatsRefs("External links", stats.ExternalLinks)
+	return nil
+}
@@ -1,80 +1,45 @@
 package lib
 
-type TaskListItem struct {
-	TaskTitle      string `json:"taskTitle"`
-	TaskID         string `json:"taskID"`
-	IsCompleted    bool   `json:"isCompleted"`
-	IsLocked       bool   `json:"isLocked"`
-	PositionNumber int    `json:"positionNumber"`
-}
+import "gl.biggo.pro/devopstrain/kurator/lib/client"
+
+type TaskListItem = client.TaskListItem
 
 type CourseInfo struct {
-	CourseTitle          string         `json:"courseTitle" yaml:"courseTitle"`
-	CourseIcon           string         `json:"courseIcon" yaml:"courseIcon"`
-	CourseSource         string         `json:"-" yaml:"courseSource"`
-	AuthorName           string         `json:"authorName" yaml:"authorName"`
-	AuthorPosition       string         `json:"authorPosition" yaml:"authorPosition"`
-	AuthorPhoto          string         `json:"authorPhoto" yaml:"authorPhoto"`
-	BaseServerHandlerURL string         `json:"-" yaml:"baseServerHandlerURL"`
-	TaskList             []TaskListItem `json:"taskList"`
+	CourseTitle          string                 `json:"courseTitle" yaml:"courseTitle"`
+	CourseIcon           string                 `json:"courseIcon" yaml:"courseIcon"`
+	CourseSource         string                 `json:"-" yaml:"courseSource"`
+	Version              string                 `json:"-" yaml:"version"`
+	AuthorName           string                 `json:"authorName" yaml:"authorName"`
+	AuthorPosition       string                 `json:"authorPosition" yaml:"authorPosition"`
+	AuthorPhoto          string                 `json:"authorPhoto" yaml:"authorPhoto"`
+	BaseServerHandlerURL string                 `json:"-" yaml:"baseServerHandlerURL"`
+	Vars                 map[string]interface{} `json:"-" yaml:"vars"`
+	TaskList             []TaskListItem         `json:"taskList"`
 }
 
 type TaskShortInfo struct {
-	TaskTitle string `json:"taskTitle" yaml:"taskTitle"`
-	TaskID    string `json:"taskID" yaml:"taskID"`
-	IsFree    bool   `json:"isFree" yaml:"isFree"`
-}
-
-type TaskInfo struct {
 	TaskTitle string   `json:"taskTitle" yaml:"taskTitle"`
 	TaskID    string   `json:"taskID" yaml:"taskID"`
 	IsFree    bool     `json:"isFree" yaml:"isFree"`
-	Intro     string   `json:"intro" yaml:"intro"`
 	DependsOn []string `json:"dependsOn" yaml:"dependsOn"`
-	Goals     []struct {
-		ID            string `json:"id" yaml:"id"`
-		StatusHandler string `json:"statusHandler" yaml:"statusHandler"`
-		RunHandler    string `json:"runHandler" yaml:"runHandler"`
-		IsCompleted   bool   `json:"isCompleted"`
-		Contents      []struct {
-			Kind string `json:"kind" yaml:"kind"`
-			Tabs []struct {
-				Title   string `json:"title" yaml:"title"`
-				Content string `json:"content" yaml:"content"`
-			} `json:"tabs,omitempty" yaml:"tabs,omitempty"`
-			Content        string `json:"content,omitempty" yaml:"content,omitempty"`
-			SourceHandler  string `json:"sourceHandler,omitempty" yaml:"sourceHandler,omitempty"`
-			CacheKey       string `json:"cacheKey,omitempty" yaml:"cacheKey,omitempty"`
-			ID             string `json:"id,omitempty" yaml:"id,omitempty"`
-			Title          string `json:"title,omitempty" yaml:"title,omitempty"`
-			Text           string `json:"text,omitempty" yaml:"text,omitempty"`
-			DisableOnClick int    `json:"disableOnClick,omitempty" yaml:"disableOnClick,omitempty"`
-			AllowMultiple  bool   `json:"allowMultiple,omitempty" yaml:"allowMultiple,omitempty"`
-			Answers        []struct {
-				Text      string `json:"text,omitempty" yaml:"text,omitempty"`
-				IsCorrect bool   `json:"isCorrect,omitempty" yaml:"isCorrect,omitempty"`
-			} `json:"answers" yaml:"answers"`
-			KuratorRequest struct {
-				APIVersion string   `yaml:"apiVersion"`
-				Type       string   `yaml:"type"`
-				Payload    string   `yaml:"payload"`
-				Command    string   `yaml:"command"`
-				Args       []string `yaml:"args"`
-				Files      []string `yaml:"files"`
-			} `yaml:"kuratorRequest"`
-		} `json:"contents" yaml:"contents"`
-	} `json:"goals" yaml:"goals"`
-	Faqs []struct {
-		Question string `json:"question" yaml:"question"`
-		Answer   string `json:"answer" yaml:"answer"`
-	} `json:"faqs" yaml:"faqs"`
-	RelatedCourses []string `json:"relatedCourses" yaml:"relatedCourses"`
+}
+
+type TaskInfo struct {
+	TaskTitle      string   `json:"taskTitle" yaml:"taskTitle"`
+	TaskID         string   `json:"taskID" yaml:"taskID"`
+	IsFree         bool     `json:"isFree" yaml:"isFree"`
+	Intro          string   `json:"intro" yaml:"intro,omitempty"`
+	DependsOn      []string `json:"dependsOn" yaml:"dependsOn,omitempty"`
+	Goals          []Goal   `json:"goals" yaml:"goals"`
+	Faqs           []Faq    `json:"faqs" yaml:"faqs,omitempty"`
+	RelatedCourses []string `json:"relatedCourses" yaml:"relatedCourses,omitempty"`
 }
 
 type RequestHandler struct {
 	Method                 string            `json:"method"`
 	CourseName             string            `json:"courseName"`
-	TaskNumber             int               `json:"taskNumber"`
+	TaskNumber             int               `json:"taskNumber"` // position of the task in the course, used if taskID is empty
+	TaskID                 string            `json:"taskID"`
 	CacheKey               string            `json:"cacheKey"`
 	KuratorCommandOutput   string            `json:"kuratorCommandOutput"`   // sets based on local command execution
 	KuratorCommandExitCode int               `json:"kuratorCommandExitCode"` // sets based on local command execution
@@ -102,6 +67,8 @@ type KuratorResponse struct {
 	CommandExitCode int               `json:"commandExitCode"`
 	BoolResponse    bool              `json:"boolResponse"`
 	FilesBase64     map[string]string `json:"filesBase64"`
+	RedactionCount  int               `json:"redactionCount"`
+	Error           string            `json:"error,omitempty"`
 	SeqID           int64             `json:"seq_id"`
 }
 
@@ -112,14 +79,16 @@ type ResponseFromHandler struct {
 type ResponseEmpty struct {
 }
 
+type GenMethod struct {
+	HandlerName     string
+	HandlerFuncName string
+	IsOutput        bool
+}
+
 type GenTask struct {
 	TaskID  string
 	IsFree  bool
-	Methods []struct {
-		HandlerName     string
-		HandlerFuncName string
-		IsOutput        bool
-	}
+	Methods []GenMethod
 }
 
 type Module struct {
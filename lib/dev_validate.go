@@ -0,0 +1,405 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+var (
+	taskFileName        = regexp.MustCompile(`^(0|[1-9]\d*)\.yaml$`)
+	knownKuratorRequest = []string{"command", "contains"}
+)
+
+// Problem is a single finding of the course linter.
+type Problem struct {
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	// Field is the YAML key of the problem inside the content item, used to locate its line
+	Field string `json:"-"`
+}
+
+func (p Problem) String() string {
+	location := p.File
+	if p.Line > 0 {
+		location = fmt.Sprintf("%s:%d", p.File, p.Line)
+	}
+	return fmt.Sprintf("%s: %s: %s", location, p.Severity, p.Message)
+}
+
+// yamlLocator finds line numbers of values in a YAML document.
+type yamlLocator struct {
+	root *yamlv3.Node
+}
+
+func newYAMLLocator(dat []byte) yamlLocator {
+	var doc yamlv3.Node
+	if yamlv3.Unmarshal(dat, &doc) != nil || len(doc.Content) == 0 {
+		return yamlLocator{}
+	}
+	return yamlLocator{root: doc.Content[0]}
+}
+
+// line returns the line of the value at path (map keys and sequence indexes),
+// or of the closest existing parent.
+func (l yamlLocator) line(path ...interface{}) int {
+	node := l.root
+	if node == nil {
+		return 0
+	}
+
+	for _, p := range path {
+		var next *yamlv3.Node
+		switch key := p.(type) {
+		case string:
+			if node.Kind == yamlv3.MappingNode {
+				for i := 0; i+1 < len(node.Content); i += 2 {
+					if node.Content[i].Value == key {
+						next = node.Content[i+1]
+						if next.Kind == yamlv3.ScalarNode {
+							next = node.Content[i]
+						}
+						break
+					}
+				}
+			}
+		case int:
+			if node.Kind == yamlv3.SequenceNode && key < len(node.Content) {
+				next = node.Content[key]
+			}
+		}
+		if next == nil {
+			break
+		}
+		node = next
+	}
+
+	return node.Line
+}
+
+// pathAt returns the path of the first node that starts at line.
+func (l yamlLocator) pathAt(line int) []interface{} {
+	var find func(node *yamlv3.Node, path []interface{}) ([]interface{}, bool)
+	find = func(node *yamlv3.Node, path []interface{}) ([]interface{}, bool) {
+		if node.Line == line {
+			return path, true
+		}
+		switch node.Kind {
+		case yamlv3.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				childPath := append(append([]interface{}{}, path...), node.Content[i].Value)
+				if node.Content[i].Line == line {
+					return childPath, true
+				}
+				if found, ok := find(node.Content[i+1], childPath); ok {
+					return found, true
+				}
+			}
+		case yamlv3.SequenceNode:
+			for n, item := range node.Content {
+				if found, ok := find(item, append(append([]interface{}{}, path...), n)); ok {
+					return found, true
+				}
+			}
+		}
+		return nil, false
+	}
+
+	if l.root == nil {
+		return nil
+	}
+	path, _ := find(l.root, nil)
+	return path
+}
+
+type courseLinter struct {
+	courseDir string
+	problems  []Problem
+}
+
+func (l *courseLinter) add(file string, line int, severity, format string, args ...interface{}) {
+	l.problems = append(l.problems, Problem{File: file, Line: line, Severity: severity, Message: fmt.Sprintf(format, args...)})
+}
+
+type lintTask struct {
+	file    string
+	number  int
+	info    TaskInfo
+	locator yamlLocator
+}
+
+// ValidateCourse checks base.yaml and task files of the course in courseDir.
+func ValidateCourse(courseDir string) ([]Problem, error) {
+	if _, err := os.Stat(courseDir); err != nil {
+		return nil, err
+	}
+
+	l := &courseLinter{courseDir: courseDir}
+	l.checkBase()
+	tasks := l.loadTasks()
+	l.checkTasks(tasks)
+
+	sort.SliceStable(l.problems, func(i, j int) bool {
+		if l.problems[i].File != l.problems[j].File {
+			return l.problems[i].File < l.problems[j].File
+		}
+		return l.problems[i].Line < l.problems[j].Line
+	})
+	return l.problems, nil
+}
+
+func (l *courseLinter) checkBase() {
+	file := filepath.Join(l.courseDir, "base.yaml")
+	dat, err := ioutil.ReadFile(file)
+	if err != nil {
+		l.add(file, 0, SeverityError, "can't read base.yaml: %v", err)
+		return
+	}
+
+	ci, err := loadCourseInfo(l.courseDir)
+	if err != nil {
+		l.addLoadError(file, err)
+		return
+	}
+
+	locator := newYAMLLocator(dat)
+	if ci.CourseTitle == "" {
+		l.add(file, locator.line("courseTitle"), SeverityError, "courseTitle is empty")
+	}
+	if ci.AuthorName == "" {
+		l.add(file, locator.line("authorName"), SeverityWarning, "authorName is empty")
+	}
+}
+
+// addLoadError reports an error of the course loader at the file and line it points to.
+func (l *courseLinter) addLoadError(file string, err error) {
+	var ye *YAMLError
+	if !errors.As(err, &ye) {
+		l.add(file, 0, SeverityError, "%v", err)
+		return
+	}
+	l.add(ye.File, ye.Line, SeverityError, "%s", ye.Message)
+}
+
+func (l *courseLinter) loadTasks() []lintTask {
+	dir := filepath.Join(l.courseDir, "tasks")
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		l.add(dir, 0, SeverityError, "can't read tasks directory: %v", err)
+		return nil
+	}
+
+	var tasks []lintTask
+	numbers := map[int]string{}
+	for _, f := range files {
+		file := filepath.Join(dir, f.Name())
+		if f.IsDir() {
+			continue
+		}
+
+		if om := taskOverlayName.FindStringSubmatch(f.Name()); om != nil {
+			l.checkOverlay(file, filepath.Join(dir, om[1]+".yaml"))
+			continue
+		}
+		m := taskFileName.FindStringSubmatch(f.Name())
+		if m == nil {
+			l.add(file, 0, SeverityError, "task file must be named N.yaml where N is the task number, or N.<lang>.yaml for translations")
+			continue
+		}
+		number, _ := strconv.Atoi(m[1])
+		if other, ok := numbers[number]; ok {
+			l.add(file, 0, SeverityError, "task number %d is also used by %s", number, other)
+			continue
+		}
+		numbers[number] = file
+
+		dat, err := ioutil.ReadFile(file)
+		if err != nil {
+			l.add(file, 0, SeverityError, "can't read task: %v", err)
+			continue
+		}
+		ti, err := loadCourseTask(l.courseDir, number)
+		if err != nil {
+			l.addLoadError(file, err)
+			continue
+		}
+
+		tasks = append(tasks, lintTask{file: file, number: number, info: ti, locator: newYAMLLocator(dat)})
+	}
+
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].number < tasks[j].number })
+
+	if len(numbers) == 0 {
+		l.add(dir, 0, SeverityError, "course has no tasks")
+	}
+	for n := 1; n <= len(numbers); n++ {
+		if _, ok := numbers[n]; !ok {
+			l.add(dir, 0, SeverityWarning, "task numbers have a gap: %d.yaml is missing. Run `kurator dev reorder` to renumber", n)
+			break
+		}
+	}
+
+	return tasks
+}
+
+// checkOverlay checks that a translation parses and has a task to translate.
+func (l *courseLinter) checkOverlay(file, baseFile string) {
+	if _, err := os.Stat(baseFile); err != nil {
+		l.add(file, 0, SeverityError, "translation of missing task %s", filepath.Base(baseFile))
+		return
+	}
+
+	dat, err := ioutil.ReadFile(file)
+	if err != nil {
+		l.add(file, 0, SeverityError, "can't read translation: %v", err)
+		return
+	}
+	var overlay interface{}
+	err = yaml.Unmarshal(dat, &overlay)
+	if err != nil {
+		ye := newYAMLError(file, err)
+		l.add(file, ye.Line, SeverityError, "%s", ye.Message)
+	}
+}
+
+func (l *courseLinter) checkTasks(tasks []lintTask) {
+	taskIDs := map[string]string{}
+	goalIDs := map[string]string{}
+	// Passed quizzes are stored per course by id, so ids must be unique across tasks
+	quizIDs := map[string]string{}
+
+	for _, task := range tasks {
+		if task.info.TaskID == "" {
+			l.add(task.file, task.locator.line("taskID"), SeverityError, "taskID is empty")
+		} else if other, ok := taskIDs[task.info.TaskID]; ok {
+			l.add(task.file, task.locator.line("taskID"), SeverityError, "taskID %s is also used by %s", task.info.TaskID, other)
+		} else {
+			taskIDs[task.info.TaskID] = task.file
+		}
+		if task.info.TaskTitle == "" {
+			l.add(task.file, task.locator.line("taskTitle"), SeverityWarning, "taskTitle is empty")
+		}
+	}
+
+	for _, task := range tasks {
+		ti := task.info
+		for n, dep := range ti.DependsOn {
+			line := task.locator.line("dependsOn", n)
+			if dep == ti.TaskID {
+				l.add(task.file, line, SeverityError, "task depends on itself")
+			} else if _, ok := taskIDs[dep]; !ok {
+				l.add(task.file, line, SeverityError, "dependsOn refers to unknown task %s", dep)
+			}
+		}
+
+		if len(ti.Goals) == 0 {
+			l.add(task.file, task.locator.line("goals"), SeverityWarning, "task has no goals")
+		}
+
+		for g, goal := range ti.Goals {
+			goalLine := task.locator.line("goals", g)
+			if goal.ID == "" {
+				l.add(task.file, goalLine, SeverityError, "goal #%d has no id", g+1)
+			} else if other, ok := goalIDs[goal.ID]; ok {
+				l.add(task.file, task.locator.line("goals", g, "id"), SeverityError, "goal id %s is also used in %s", goal.ID, other)
+			} else {
+				goalIDs[goal.ID] = task.file
+			}
+			if !goalIsRequired(goal) {
+				l.add(task.file, goalLine, SeverityWarning, "goal %s has no statusHandler or quiz, it is informational and not required to complete the task", goal.ID)
+			}
+
+			hasButton := false
+			for k, content := range goal.Contents {
+				line := task.locator.line("goals", g, "contents", k)
+
+				for _, p := range content.Validate() {
+					problemLine := line
+					if p.Field != "" {
+						problemLine = task.locator.line("goals", g, "contents", k, p.Field)
+					}
+					l.add(task.file, problemLine, p.Severity, "%s", p.Message)
+				}
+				if content.Kind == "button" {
+					hasButton = true
+				}
+				if quiz, ok := content.Block.(*QuizBlock); ok && quiz.ID != "" {
+					if other, ok := quizIDs[quiz.ID]; ok {
+						l.add(task.file, task.locator.line("goals", g, "contents", k, "id"), SeverityError, "quiz id %s is also used in %s", quiz.ID, other)
+					} else {
+						quizIDs[quiz.ID] = task.file
+					}
+				}
+
+				kr := content.KuratorRequest
+				krLine := task.locator.line("goals", g, "contents", k, "kuratorRequest")
+				if kr.Type != "" || kr.Payload != "" {
+					if !StringSliceContains(knownKuratorRequest, kr.Type) {
+						l.add(task.file, task.locator.line("goals", g, "contents", k, "kuratorRequest", "type"), SeverityError,
+							"unknown kuratorRequest.type %q. Known types: %s", kr.Type, strings.Join(knownKuratorRequest, ", "))
+					}
+					if kr.Payload == "" {
+						l.add(task.file, krLine, SeverityError, "kuratorRequest has no payload")
+					}
+					if kr.Type == "contains" && len(kr.Files) == 0 {
+						l.add(task.file, krLine, SeverityError, "kuratorRequest of type contains has no files")
+					}
+					if goal.RunHandler == "" && content.OutputHandler() == "" {
+						l.add(task.file, krLine, SeverityWarning, "kuratorRequest is never sent: goal has no runHandler and content has no sourceHandler")
+					}
+				}
+			}
+
+			if hasButton && goal.RunHandler == "" {
+				l.add(task.file, goalLine, SeverityError, "goal %s has a button but no runHandler", goal.ID)
+			}
+		}
+	}
+}
+
+func ValidateCourseCLI(c *cli.Context) error {
+	courseName := c.String("course_name")
+	strict := c.Bool("strict")
+
+	problems, err := ValidateCourse(courseName)
+	if err != nil {
+		return err
+	}
+
+	errorsCount, warningsCount := 0, 0
+	for _, p := range problems {
+		if strict && p.Severity == SeverityWarning {
+			p.Severity = SeverityError
+		}
+		if p.Severity == SeverityError {
+			errorsCount++
+		} else {
+			warningsCount++
+		}
+		fmt.Println(p)
+	}
+
+	if errorsCount > 0 {
+		return cli.Exit(fmt.Sprintf("\n%d error(s), %d warning(s)", errorsCount, warningsCount), 1)
+	}
+
+	fmt.Printf("Course %s is valid: %d warning(s)\n", courseName, warningsCount)
+	return nil
+}
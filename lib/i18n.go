@@ -0,0 +1,127 @@
+package lib
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+const defaultLang = "en"
+
+var (
+	//go:embed i18n
+	catalogFS embed.FS
+
+	catalogOnce sync.Once
+	catalog     map[string]map[string]string
+)
+
+// loadCatalog reads the embedded i18n/<lang>.yaml message files.
+func loadCatalog() map[string]map[string]string {
+	catalogOnce.Do(func() {
+		catalog = map[string]map[string]string{}
+		entries, err := catalogFS.ReadDir("i18n")
+		if err != nil {
+			panic(err)
+		}
+		for _, entry := range entries {
+			dat, err := catalogFS.ReadFile(path.Join("i18n", entry.Name()))
+			if err != nil {
+				panic(err)
+			}
+			messages := map[string]string{}
+			err = yaml.Unmarshal(dat, &messages)
+			if err != nil {
+				panic(fmt.Sprintf("i18n/%s: %v", entry.Name(), err))
+			}
+			catalog[strings.TrimSuffix(entry.Name(), ".yaml")] = messages
+		}
+	})
+	return catalog
+}
+
+// catalogLang returns the first of langs that has a message catalog.
+func catalogLang(langs []string) string {
+	messages := loadCatalog()
+	for _, lang := range langs {
+		if _, ok := messages[lang]; ok {
+			return lang
+		}
+	}
+	return defaultLang
+}
+
+// T returns the message id translated to the first supported of langs,
+// falling back to English.
+func T(langs []string, id string, args ...interface{}) string {
+	messages := loadCatalog()
+	message, ok := messages[catalogLang(langs)][id]
+	if !ok {
+		message, ok = messages[defaultLang][id]
+	}
+	if !ok {
+		message = id
+	}
+
+	if len(args) > 0 {
+		return fmt.Sprintf(message, args...)
+	}
+	return message
+}
+
+// localLangs returns the language of the terminal kurator runs in, taken from
+// the locale variables, e.g. "ru" for LANG=ru_RU.UTF-8.
+func localLangs() []string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		parts := strings.FieldsFunc(os.Getenv(name), func(r rune) bool { return r == '_' || r == '.' || r == '@' })
+		if len(parts) == 0 {
+			continue
+		}
+		lang := strings.ToLower(parts[0])
+		if lang == "c" || lang == "posix" {
+			return nil
+		}
+		return []string{lang}
+	}
+	return nil
+}
+
+// messageError is an error with a catalog message. It is shown in the
+// terminal language, localizeError translates it for a browser.
+type messageError struct {
+	id   string
+	args []interface{}
+}
+
+func errorT(id string, args ...interface{}) error {
+	return &messageError{id: id, args: args}
+}
+
+func (e *messageError) Error() string {
+	return T(localLangs(), e.id, e.args...)
+}
+
+// Unwrap returns the error the message is about, if any.
+func (e *messageError) Unwrap() error {
+	for _, arg := range e.args {
+		if err, ok := arg.(error); ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// localizeError returns the message of err in the first supported of langs.
+func localizeError(langs []string, err error) string {
+	var me *messageError
+	if errors.As(err, &me) {
+		return T(langs, me.id, me.args...)
+	}
+	return err.Error()
+}
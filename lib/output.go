@@ -0,0 +1,78 @@
+package lib
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v2"
+)
+
+var outputFormats = []string{"table", "json", "yaml", "csv"}
+
+// OutputFlag selects how command results are printed.
+func OutputFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:    "output",
+		Aliases: []string{"o"},
+		Usage:   "Output format: table, json, yaml or csv",
+		Value:   "table",
+	}
+}
+
+func checkOutputFormat(format string) error {
+	if !StringSliceContains(outputFormats, format) {
+		return fmt.Errorf("unknown output format %q. Use one of: table, json, yaml, csv", format)
+	}
+	return nil
+}
+
+// renderOutput prints data as JSON/YAML, or header and rows as a table/CSV.
+func renderOutput(format string, header []string, rows [][]string, data interface{}) error {
+	switch format {
+	case "json":
+		dataJson, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(dataJson))
+	case "yaml":
+		// Go through JSON so that YAML keys match the JSON field names
+		dataJson, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		var generic interface{}
+		err = yaml.Unmarshal(dataJson, &generic)
+		if err != nil {
+			return err
+		}
+		dataYaml, err := yaml.Marshal(generic)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(dataYaml))
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		err := w.Write(header)
+		if err != nil {
+			return err
+		}
+		err = w.WriteAll(rows)
+		if err != nil {
+			return err
+		}
+	case "table", "":
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader(header)
+		table.AppendBulk(rows)
+		table.Render()
+	default:
+		return checkOutputFormat(format)
+	}
+
+	return nil
+}
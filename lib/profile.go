@@ -0,0 +1,295 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	defaultProfile   = "default"
+	profileFlagUsage = "Profile (account and API endpoint) to use"
+)
+
+var defaultTargetURL = targetURL
+
+var activeProfile = defaultProfile
+
+var validProfileName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+type Profile struct {
+	APIURL string `yaml:"apiURL,omitempty"`
+	Email  string `yaml:"email,omitempty"`
+}
+
+type ProfileConfig struct {
+	Current  string             `yaml:"current"`
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// ProfileFlag selects the account/environment profile for a command.
+func ProfileFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:    "profile",
+		Usage:   profileFlagUsage,
+		EnvVars: []string{"KURATOR_PROFILE"},
+	}
+}
+
+// WithProfileFlag adds --profile to every command of the tree, so the profile
+// can also be given after the command name. The app-level flag and
+// KURATOR_PROFILE are applied by SelectProfile before any command runs.
+// `profile` commands take the profile name as an argument and get no flag.
+func WithProfileFlag(commands []*cli.Command) []*cli.Command {
+	for _, command := range commands {
+		if command.Name == "profile" {
+			continue
+		}
+		if len(command.Subcommands) > 0 {
+			command.Subcommands = WithProfileFlag(command.Subcommands)
+			continue
+		}
+
+		command.Flags = append(command.Flags, &cli.StringFlag{
+			Name:  "profile",
+			Usage: profileFlagUsage,
+		})
+		before := command.Before
+		command.Before = func(c *cli.Context) error {
+			if c.IsSet("profile") {
+				err := SelectProfile(c)
+				if err != nil {
+					return err
+				}
+			}
+			if before != nil {
+				return before(c)
+			}
+			return nil
+		}
+	}
+	return commands
+}
+
+func profileConfigPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".config", "kurator", "profiles.yaml"), nil
+}
+
+func loadProfileConfig() (ProfileConfig, error) {
+	pc := ProfileConfig{Profiles: map[string]Profile{}}
+
+	configPath, err := profileConfigPath()
+	if err != nil {
+		return pc, err
+	}
+
+	dat, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return pc, nil
+		}
+		return pc, err
+	}
+
+	err = yaml.Unmarshal(dat, &pc)
+	if err != nil {
+		return pc, fmt.Errorf("failed to parse %s: %w", configPath, err)
+	}
+	if pc.Profiles == nil {
+		pc.Profiles = map[string]Profile{}
+	}
+
+	return pc, nil
+}
+
+func saveProfileConfig(pc ProfileConfig) error {
+	configPath, err := profileConfigPath()
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(configPath), 0700)
+	if err != nil {
+		return err
+	}
+
+	dat, err := yaml.Marshal(pc)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(configPath, dat, 0600)
+}
+
+// SelectProfile activates the profile given by --profile, KURATOR_PROFILE or
+// the one chosen with `kurator profile use`, and points API calls at its endpoint.
+func SelectProfile(c *cli.Context) error {
+	pc, err := loadProfileConfig()
+	if err != nil {
+		return err
+	}
+
+	name := c.String("profile")
+	if name == "" {
+		name = pc.Current
+	}
+	if name == "" {
+		name = defaultProfile
+	}
+	if !validProfileName.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q. It must contain only letters, digits, dash and underscore", name)
+	}
+
+	activeProfile = name
+	targetURL = defaultTargetURL
+	if p, ok := pc.Profiles[name]; ok && p.APIURL != "" {
+		targetURL = strings.TrimSuffix(p.APIURL, "/")
+	}
+
+	return nil
+}
+
+// updateActiveProfile records login details for the active profile.
+func updateActiveProfile(email, apiURL string) error {
+	pc, err := loadProfileConfig()
+	if err != nil {
+		return err
+	}
+
+	p := pc.Profiles[activeProfile]
+	if email != "" {
+		p.Email = email
+	}
+	if apiURL != "" {
+		p.APIURL = strings.TrimSuffix(apiURL, "/")
+		targetURL = p.APIURL
+	}
+	pc.Profiles[activeProfile] = p
+
+	return saveProfileConfig(pc)
+}
+
+func ListProfiles(c *cli.Context) error {
+	pc, err := loadProfileConfig()
+	if err != nil {
+		return err
+	}
+
+	current := pc.Current
+	if current == "" {
+		current = defaultProfile
+	}
+
+	names := []string{}
+	for name := range pc.Profiles {
+		names = append(names, name)
+	}
+	if _, ok := pc.Profiles[defaultProfile]; !ok {
+		names = append(names, defaultProfile)
+	}
+	sort.Strings(names)
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Current", "Name", "Email", "API URL", "Logged In"})
+
+	for _, name := range names {
+		p := pc.Profiles[name]
+		apiURL := p.APIURL
+		if apiURL == "" {
+			apiURL = defaultTargetURL
+		}
+		marker := ""
+		if name == current {
+			marker = "*"
+		}
+		loggedIn := false
+		store, err := newTokenStoreFor(name)
+		if err == nil {
+			_, err = store.Get()
+			loggedIn = err == nil
+		}
+		table.Append([]string{marker, name, p.Email, apiURL, fmt.Sprintf("%t", loggedIn)})
+	}
+
+	table.Render()
+	return nil
+}
+
+func UseProfile(c *cli.Context) error {
+	name := c.Args().First()
+	if name == "" {
+		return errors.New("missing profile name")
+	}
+
+	pc, err := loadProfileConfig()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := pc.Profiles[name]; !ok && name != defaultProfile {
+		return fmt.Errorf("profile %s does not exist. Create it with `kurator login --profile %s`", name, name)
+	}
+
+	pc.Current = name
+	err = saveProfileConfig(pc)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Switched to profile %s\n", name)
+	return nil
+}
+
+func DeleteProfile(c *cli.Context) error {
+	name := c.Args().First()
+	if name == "" {
+		return errors.New("missing profile name")
+	}
+	if name == defaultProfile {
+		return fmt.Errorf("the %s profile can't be deleted. Run `kurator logout --profile %s` to remove its credentials", defaultProfile, defaultProfile)
+	}
+
+	pc, err := loadProfileConfig()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := pc.Profiles[name]; !ok {
+		return fmt.Errorf("profile %s does not exist", name)
+	}
+
+	store, err := newTokenStoreFor(name)
+	if err != nil {
+		return err
+	}
+	err = store.Delete()
+	if err != nil {
+		return err
+	}
+
+	delete(pc.Profiles, name)
+	if pc.Current == name {
+		pc.Current = ""
+	}
+
+	err = saveProfileConfig(pc)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Profile %s deleted\n", name)
+	return nil
+}
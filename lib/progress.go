@@ -0,0 +1,155 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+	"gl.biggo.pro/devopstrain/kurator/lib/client"
+)
+
+type GoalProgress struct {
+	ID          string `json:"id"`
+	IsCompleted bool   `json:"isCompleted"`
+}
+
+type TaskProgress struct {
+	Number      int            `json:"number"`
+	TaskID      string         `json:"taskID"`
+	TaskTitle   string         `json:"taskTitle"`
+	IsCompleted bool           `json:"isCompleted"`
+	IsLocked    bool           `json:"isLocked"`
+	Goals       []GoalProgress `json:"goals"`
+}
+
+type CourseProgress struct {
+	ShortName      string         `json:"shortName"`
+	CourseTitle    string         `json:"courseTitle"`
+	TasksTotal     int            `json:"tasksTotal"`
+	TasksCompleted int            `json:"tasksCompleted"`
+	GoalsTotal     int            `json:"goalsTotal"`
+	GoalsCompleted int            `json:"goalsCompleted"`
+	Percent        int            `json:"percent"`
+	Tasks          []TaskProgress `json:"tasks"`
+}
+
+func CourseProgressCLI(c *cli.Context) error {
+	shortName := c.Args().First()
+	if shortName == "" {
+		return errorT("course_name_missing")
+	}
+
+	format := c.String("output")
+	err := checkOutputFormat(format)
+	if err != nil {
+		return err
+	}
+
+	authCompleted, token := CheckAuthCompleted()
+	if !authCompleted {
+		return errorT("auth_not_completed")
+	}
+
+	progress, err := fetchCourseProgress(newPlatformClient(token), shortName)
+	if err != nil {
+		return err
+	}
+
+	if format == "table" {
+		printProgressChecklist(progress)
+		return nil
+	}
+
+	var rows [][]string
+	for _, task := range progress.Tasks {
+		completedGoals := 0
+		for _, goal := range task.Goals {
+			if goal.IsCompleted {
+				completedGoals++
+			}
+		}
+		rows = append(rows, []string{
+			fmt.Sprintf("%d", task.Number),
+			task.TaskID,
+			task.TaskTitle,
+			fmt.Sprintf("%t", task.IsCompleted),
+			fmt.Sprintf("%t", task.IsLocked),
+			fmt.Sprintf("%d/%d", completedGoals, len(task.Goals)),
+		})
+	}
+
+	return renderOutput(format, []string{"#", "Task ID", "Title", "Completed", "Locked", "Goals"}, rows, progress)
+}
+
+func fetchCourseProgress(pc *client.Client, shortName string) (CourseProgress, error) {
+	progress := CourseProgress{ShortName: shortName, Tasks: []TaskProgress{}}
+
+	details, err := pc.GetCourse(context.Background(), shortName)
+	if err != nil {
+		return progress, err
+	}
+	progress.CourseTitle = details.CourseTitle
+
+	for _, item := range details.TaskList {
+		task := TaskProgress{
+			Number:      item.PositionNumber + 1,
+			TaskID:      item.TaskID,
+			TaskTitle:   item.TaskTitle,
+			IsCompleted: item.IsCompleted,
+			IsLocked:    item.IsLocked,
+			Goals:       []GoalProgress{},
+		}
+
+		// Locked tasks are not available to the student, so goals are unknown
+		if !item.IsLocked {
+			courseTask, err := pc.GetCourseTask(context.Background(), shortName, task.Number)
+			if err != nil {
+				return progress, errorT("progress_task_failed", item.TaskID, err)
+			}
+			for _, goal := range courseTask.Goals {
+				task.Goals = append(task.Goals, GoalProgress{ID: goal.ID, IsCompleted: goal.IsCompleted})
+				progress.GoalsTotal++
+				if goal.IsCompleted {
+					progress.GoalsCompleted++
+				}
+			}
+		}
+
+		progress.TasksTotal++
+		if task.IsCompleted {
+			progress.TasksCompleted++
+		}
+		progress.Tasks = append(progress.Tasks, task)
+	}
+
+	if progress.TasksTotal > 0 {
+		progress.Percent = progress.TasksCompleted * 100 / progress.TasksTotal
+	}
+
+	return progress, nil
+}
+
+func printProgressChecklist(progress CourseProgress) {
+	langs := localLangs()
+	fmt.Println(T(langs, "progress_summary",
+		progress.CourseTitle, progress.TasksCompleted, progress.TasksTotal, progress.Percent, progress.GoalsCompleted, progress.GoalsTotal))
+	fmt.Println()
+
+	for _, task := range progress.Tasks {
+		suffix := ""
+		if task.IsLocked {
+			suffix = T(langs, "progress_locked")
+		}
+		fmt.Printf("%s %d. %s [%s]%s\n", checkMark(task.IsCompleted), task.Number, task.TaskTitle, task.TaskID, suffix)
+		for _, goal := range task.Goals {
+			fmt.Printf("    %s %s\n", checkMark(goal.IsCompleted), goal.ID)
+		}
+	}
+}
+
+func checkMark(done bool) string {
+	if done {
+		return "[x]"
+	}
+	return "[ ]"
+}
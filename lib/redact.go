@@ -0,0 +1,145 @@
+package lib
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"gopkg.in/yaml.v2"
+)
+
+const redactedPlaceholder = "[REDACTED]"
+
+type redactRule struct {
+	Name    string
+	Pattern *regexp.Regexp
+	// Group is the submatch index to replace. Zero replaces the whole match.
+	Group int
+}
+
+// Built-in detectors for secrets commonly found in command output and config files.
+var builtinRedactRules = []redactRule{
+	{Name: "private-key", Pattern: regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z0-9 ]*PRIVATE KEY-----`)},
+	{Name: "aws-access-key-id", Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{Name: "aws-secret-access-key", Pattern: regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})`), Group: 1},
+	{Name: "jwt", Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{5,}`)},
+	{Name: "kubeconfig-client-key", Pattern: regexp.MustCompile(`(?m)^\s*(?:client-key-data|client-certificate-data|token|id-token|refresh-token):\s*["']?([^\s"']+)`), Group: 1},
+	{Name: "password", Pattern: regexp.MustCompile(`(?i)\b[a-z0-9_.-]*(?:password|passwd|secret|api_?key|access_?token)["']?\s*[:=]\s*["']?([^\s"'&;,]+)`), Group: 1},
+}
+
+// Redactor masks secrets in data leaving the student machine.
+type Redactor struct {
+	rules []redactRule
+}
+
+type redactConfig struct {
+	Patterns []string `yaml:"patterns"`
+}
+
+// NewRedactor builds a redactor from built-in detectors plus user supplied
+// regular expressions. Patterns from ~/.config/kurator/redact.yaml are
+// appended as well when the file exists.
+func NewRedactor(patterns []string) (*Redactor, error) {
+	r := &Redactor{rules: append([]redactRule{}, builtinRedactRules...)}
+
+	configPatterns, err := loadRedactConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range append(configPatterns, patterns...) {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", p, err)
+		}
+		group := 0
+		if re.NumSubexp() > 0 {
+			group = 1
+		}
+		r.rules = append(r.rules, redactRule{Name: "custom", Pattern: re, Group: group})
+	}
+
+	return r, nil
+}
+
+func loadRedactConfig() ([]string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, nil
+	}
+
+	dat, err := ioutil.ReadFile(filepath.Join(homeDir, ".config", "kurator", "redact.yaml"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	rc := redactConfig{}
+	err = yaml.Unmarshal(dat, &rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse redact.yaml: %w", err)
+	}
+
+	return rc.Patterns, nil
+}
+
+// Redact returns text with all detected secrets replaced and the number of replacements made.
+func (r *Redactor) Redact(text string) (string, int) {
+	if r == nil {
+		return text, 0
+	}
+
+	count := 0
+	for _, rule := range r.rules {
+		text = rule.Pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if rule.Group == 0 {
+				count++
+				return redactedPlaceholder
+			}
+			sub := rule.Pattern.FindStringSubmatchIndex(match)
+			if len(sub) <= 2*rule.Group+1 || sub[2*rule.Group] < 0 {
+				return match
+			}
+			start, end := sub[2*rule.Group], sub[2*rule.Group+1]
+			if match[start:end] == redactedPlaceholder {
+				return match
+			}
+			count++
+			return match[:start] + redactedPlaceholder + match[end:]
+		})
+	}
+
+	return text, count
+}
+
+// RedactResponse masks secrets in command output and uploaded files of kresp
+// and records the number of redactions made.
+func (r *Redactor) RedactResponse(kresp *KuratorResponse) {
+	if r == nil {
+		return
+	}
+
+	total := 0
+	output, n := r.Redact(kresp.CommandOutput)
+	kresp.CommandOutput = output
+	total += n
+
+	for name, encoded := range kresp.FilesBase64 {
+		content, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			continue
+		}
+		redacted, n := r.Redact(string(content))
+		if n > 0 {
+			kresp.FilesBase64[name] = base64.StdEncoding.EncodeToString([]byte(redacted))
+			total += n
+		}
+	}
+
+	kresp.RedactionCount = total
+}
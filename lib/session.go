@@ -0,0 +1,49 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"gl.biggo.pro/devopstrain/kurator/lib/client"
+)
+
+// newPlatformClient returns an API client for the active profile. Refreshed
+// tokens are stored unless the token came from KURATOR_TOKEN.
+func newPlatformClient(token string) *client.Client {
+	pc := client.New(targetURL, token)
+	pc.OnTokenRefresh = func(newToken string) {
+		if os.Getenv(tokenEnvVar) != "" {
+			return
+		}
+		err := saveToken(newToken)
+		if err != nil {
+			fmt.Println("Failed to save refreshed token:", err)
+			return
+		}
+		fmt.Println("Session token refreshed.")
+	}
+	return pc
+}
+
+// requireValidSession checks that a token is present and accepted by the
+// platform. Network failures are reported but not fatal so that long running
+// commands can keep retrying.
+func requireValidSession() (string, error) {
+	authCompleted, token := CheckAuthCompleted()
+	if !authCompleted {
+		return "", fmt.Errorf("authentication not completed, run `kurator login`")
+	}
+
+	pc := newPlatformClient(token)
+	_, err := pc.UserInfo(context.Background())
+	if err != nil {
+		if client.IsSessionError(err) {
+			return "", err
+		}
+		fmt.Println("Could not verify session:", err)
+	}
+
+	// The token might have been refreshed during the check
+	return pc.Token, nil
+}
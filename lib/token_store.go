@@ -0,0 +1,235 @@
+package lib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const keyringService = "kurator"
+
+var errTokenNotFound = errors.New("token not found")
+
+// TokenStore keeps the platform bearer token between kurator runs.
+type TokenStore interface {
+	Name() string
+	Get() (string, error)
+	Set(token string) error
+	Delete() error
+}
+
+// NewTokenStore returns the token store of the active profile.
+func NewTokenStore() (TokenStore, error) {
+	return newTokenStoreFor(activeProfile)
+}
+
+// newTokenStoreFor returns the OS keyring store when one is available and
+// falls back to the 0600 token file in ~/.config/kurator otherwise.
+func newTokenStoreFor(profile string) (TokenStore, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	tokenPath := filepath.Join(homeDir, ".config", "kurator", "token")
+	if profile != defaultProfile {
+		tokenPath = filepath.Join(homeDir, ".config", "kurator", "profiles", profile, "token")
+	}
+	file := &fileTokenStore{path: tokenPath}
+
+	keyring := newKeyringTokenStore(profile)
+	if keyring == nil {
+		return file, nil
+	}
+
+	return &fallbackTokenStore{primary: keyring, fallback: file}, nil
+}
+
+type fileTokenStore struct {
+	path string
+}
+
+func (s *fileTokenStore) Name() string {
+	return "file " + s.path
+}
+
+func (s *fileTokenStore) Get() (string, error) {
+	tokenBytes, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", errTokenNotFound
+		}
+		return "", err
+	}
+
+	token := strings.TrimSpace(string(tokenBytes))
+	if token == "" {
+		return "", errTokenNotFound
+	}
+	return token, nil
+}
+
+func (s *fileTokenStore) Set(token string) error {
+	err := os.MkdirAll(filepath.Dir(s.path), 0700)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(s.path, []byte(token), 0600)
+}
+
+func (s *fileTokenStore) Delete() error {
+	err := os.Remove(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// keyringTokenStore talks to the Secret Service on Linux (secret-tool) and to
+// the login keychain on macOS (security).
+type keyringTokenStore struct {
+	account string
+	tool    string
+}
+
+func newKeyringTokenStore(account string) *keyringTokenStore {
+	var tool string
+	switch runtime.GOOS {
+	case "linux":
+		tool = "secret-tool"
+		if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+			return nil
+		}
+	case "darwin":
+		tool = "security"
+	default:
+		return nil
+	}
+
+	if _, err := exec.LookPath(tool); err != nil {
+		return nil
+	}
+
+	return &keyringTokenStore{account: account, tool: tool}
+}
+
+func (s *keyringTokenStore) Name() string {
+	return "keyring (" + s.tool + ")"
+}
+
+func (s *keyringTokenStore) Get() (string, error) {
+	var cmd *exec.Cmd
+	if s.tool == "security" {
+		cmd = exec.Command(s.tool, "find-generic-password", "-s", keyringService, "-a", s.account, "-w")
+	} else {
+		cmd = exec.Command(s.tool, "lookup", "service", keyringService, "account", s.account)
+	}
+
+	output, err := cmd.Output()
+	token := strings.TrimSpace(string(output))
+	if err != nil || token == "" {
+		return "", errTokenNotFound
+	}
+	return token, nil
+}
+
+func (s *keyringTokenStore) Set(token string) error {
+	var cmd *exec.Cmd
+	if s.tool == "security" {
+		// Run the command in interactive mode so the token goes through stdin
+		// and is not visible to other users in the process list
+		cmd = exec.Command(s.tool, "-i")
+		cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+			securityQuote(keyringService), securityQuote(s.account), securityQuote(token)))
+	} else {
+		cmd = exec.Command(s.tool, "store", "--label", "Kurator token ("+s.account+")", "service", keyringService, "account", s.account)
+		cmd.Stdin = strings.NewReader(token)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("%s failed: %w %s", s.tool, err, strings.TrimSpace(stderr.String()))
+	}
+
+	// security -i exits with 0 even when the command fails, so read the token back
+	if s.tool == "security" {
+		stored, err := s.Get()
+		if err != nil || stored != token {
+			return fmt.Errorf("%s failed to store the token %s", s.tool, strings.TrimSpace(stderr.String()))
+		}
+	}
+	return nil
+}
+
+// securityQuote quotes an argument for a `security -i` command line.
+func securityQuote(arg string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+}
+
+func (s *keyringTokenStore) Delete() error {
+	var cmd *exec.Cmd
+	if s.tool == "security" {
+		cmd = exec.Command(s.tool, "delete-generic-password", "-s", keyringService, "-a", s.account)
+	} else {
+		cmd = exec.Command(s.tool, "clear", "service", keyringService, "account", s.account)
+	}
+
+	// Deleting a missing entry is not an error for logout purposes
+	_ = cmd.Run()
+	return nil
+}
+
+// fallbackTokenStore prefers the keyring and keeps the plaintext file only
+// when the keyring is unusable (e.g. locked or no session bus).
+type fallbackTokenStore struct {
+	primary  TokenStore
+	fallback TokenStore
+	used     TokenStore
+}
+
+func (s *fallbackTokenStore) Name() string {
+	if s.used != nil {
+		return s.used.Name()
+	}
+	return s.primary.Name()
+}
+
+func (s *fallbackTokenStore) Get() (string, error) {
+	token, err := s.primary.Get()
+	if err == nil {
+		s.used = s.primary
+		return token, nil
+	}
+
+	s.used = s.fallback
+	return s.fallback.Get()
+}
+
+func (s *fallbackTokenStore) Set(token string) error {
+	err := s.primary.Set(token)
+	if err != nil {
+		fmt.Printf("Keyring is not available (%v), storing token in file\n", err)
+		s.used = s.fallback
+		return s.fallback.Set(token)
+	}
+
+	// Token is now in the keyring, so do not leave a plaintext copy behind
+	s.used = s.primary
+	return s.fallback.Delete()
+}
+
+func (s *fallbackTokenStore) Delete() error {
+	err := s.primary.Delete()
+	if err != nil {
+		return err
+	}
+	return s.fallback.Delete()
+}
@@ -0,0 +1,36 @@
+// Injected by `kurator dev run-server`: reloads the page when course files
+// change and shows YAML errors on top of the course.
+(function () {
+  var overlay = null;
+
+  function showError(err) {
+    if (!overlay) {
+      overlay = document.createElement("div");
+      overlay.style.cssText = "position:fixed;top:0;left:0;right:0;bottom:0;z-index:100000;" +
+        "background:rgba(20,20,20,0.92);color:#fff;font-family:monospace;padding:32px;overflow:auto";
+      document.body.appendChild(overlay);
+    }
+    var location = err.file + (err.line ? ":" + err.line : "");
+    overlay.innerHTML = "";
+    var title = document.createElement("h2");
+    title.style.color = "#ff6b6b";
+    title.textContent = "YAML error in " + location;
+    var message = document.createElement("pre");
+    message.style.whiteSpace = "pre-wrap";
+    message.textContent = err.message;
+    var hint = document.createElement("p");
+    hint.style.color = "#aaa";
+    hint.textContent = "Fix the file and save it, the page reloads automatically.";
+    overlay.appendChild(title);
+    overlay.appendChild(message);
+    overlay.appendChild(hint);
+  }
+
+  var source = new EventSource("/dev/events");
+  source.addEventListener("reload", function () {
+    window.location.reload();
+  });
+  source.addEventListener("yaml-error", function (ev) {
+    showError(JSON.parse(ev.data));
+  });
+})();
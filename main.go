@@ -12,7 +12,11 @@ func main() {
 	app := &cli.App{
 		Name:  "Kurator",
 		Usage: "Devopstrain course helper for students and developers",
-		Commands: []*cli.Command{
+		Flags: []cli.Flag{
+			lib.ProfileFlag(),
+		},
+		Before: lib.SelectProfile,
+		Commands: lib.WithProfileFlag([]*cli.Command{
 			{
 				Name:    "login",
 				Usage:   "Login to the application",
@@ -20,9 +24,83 @@ func main() {
 				Action:  lib.LoginUserCLI,
 				Flags: []cli.Flag{
 					&cli.StringFlag{
-						Name:     "email",
-						Usage:    "Email address",
-						Required: true,
+						Name:  "email",
+						Usage: "Email address. Not needed when KURATOR_TOKEN is set",
+					},
+					&cli.BoolFlag{
+						Name:  "password-stdin",
+						Usage: "Read password from stdin. KURATOR_PASSWORD environment variable may be used instead",
+					},
+					&cli.StringFlag{
+						Name:  "api-url",
+						Usage: "Platform API URL to store in the profile. Sample: https://api.lifeisfile.com",
+					},
+				},
+			},
+			{
+				Name:   "logout",
+				Usage:  "Logout and remove local credentials",
+				Action: lib.LogoutUserCLI,
+			},
+			{
+				Name:   "whoami",
+				Usage:  "Show current account",
+				Action: lib.WhoAmICLI,
+			},
+			{
+				Name:  "account",
+				Usage: "Manage your account",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "reset-password",
+						Usage:  "Send password reset link to email",
+						Action: lib.ResetPasswordCLI,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "email",
+								Usage:    "Email address",
+								Required: true,
+							},
+						},
+					},
+					{
+						Name:   "change-password",
+						Usage:  "Change password of current account",
+						Action: lib.ChangePasswordCLI,
+					},
+					{
+						Name:   "delete",
+						Usage:  "Delete current account",
+						Action: lib.DeleteAccountCLI,
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "yes",
+								Usage: "Do not ask for confirmation",
+							},
+						},
+					},
+				},
+			},
+			{
+				Name:  "profile",
+				Usage: "Manage account and environment profiles",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "list",
+						Usage:  "List profiles",
+						Action: lib.ListProfiles,
+					},
+					{
+						Name:      "use",
+						Usage:     "Make profile current",
+						ArgsUsage: "<name>",
+						Action:    lib.UseProfile,
+					},
+					{
+						Name:      "delete",
+						Usage:     "Delete profile and its credentials",
+						ArgsUsage: "<name>",
+						Action:    lib.DeleteProfile,
 					},
 				},
 			},
@@ -52,6 +130,51 @@ func main() {
 						Name:   "list",
 						Usage:  "List all courses",
 						Action: lib.ListCourses,
+						Flags: []cli.Flag{
+							lib.OutputFlag(),
+							&cli.BoolFlag{
+								Name:  "paid",
+								Usage: "Show only paid courses",
+							},
+							&cli.BoolFlag{
+								Name:  "free",
+								Usage: "Show only free courses",
+							},
+							&cli.BoolFlag{
+								Name:  "drafts",
+								Usage: "Show only draft courses",
+							},
+							&cli.StringFlag{
+								Name:  "author",
+								Usage: "Show only courses whose author contains this text",
+							},
+							&cli.StringFlag{
+								Name:  "sort",
+								Usage: "Sort by field: short_name, full_name or author",
+							},
+							&cli.BoolFlag{
+								Name:  "reverse",
+								Usage: "Reverse sort order",
+							},
+						},
+					},
+					{
+						Name:      "show",
+						Usage:     "Show course details and task list",
+						ArgsUsage: "<short_name>",
+						Action:    lib.ShowCourse,
+						Flags: []cli.Flag{
+							lib.OutputFlag(),
+						},
+					},
+					{
+						Name:      "progress",
+						Usage:     "Show your task and goal completion for a course",
+						ArgsUsage: "<short_name>",
+						Action:    lib.CourseProgressCLI,
+						Flags: []cli.Flag{
+							lib.OutputFlag(),
+						},
 					},
 					{
 						Name:   "start",
@@ -62,6 +185,14 @@ func main() {
 								Name:  "dev",
 								Usage: "Run dev commands. Use only if you're developer",
 							},
+							&cli.StringFlag{
+								Name:  "api-url",
+								Usage: "Platform API URL to connect to. Use http://localhost:4321 to connect to local dev server",
+							},
+							&cli.StringSliceFlag{
+								Name:  "redact-pattern",
+								Usage: "Additional regexp for secrets to mask in command output and files. First capture group is masked if present",
+							},
 						},
 					},
 				},
@@ -85,6 +216,223 @@ func main() {
 								Usage:    "Your backend handler url",
 								Required: true,
 							},
+							&cli.BoolFlag{
+								Name:  "offline",
+								Usage: "Do not use the platform: accept any login and run kurator requests only on agents connected to this server",
+							},
+							&cli.BoolFlag{
+								Name:  "simulate-paid",
+								Usage: "Preview the course as a paying student. By default tasks without isFree are locked",
+							},
+							&cli.StringFlag{
+								Name:  "lang",
+								Usage: "Show the course in this language (tasks/N.<lang>.yaml translations). By default the browser Accept-Language is used",
+							},
+						},
+					},
+					{
+						Name:   "validate",
+						Usage:  "Check course YAML files for mistakes",
+						Action: lib.ValidateCourseCLI,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "course_name",
+								Usage:    "Course name",
+								Required: true,
+							},
+							&cli.BoolFlag{
+								Name:  "strict",
+								Usage: "Treat warnings as errors",
+							},
+						},
+					},
+					{
+						Name:   "schema",
+						Usage:  "Print JSON Schema of course files or save it into a course for editor completion",
+						Action: lib.SchemaCLI,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "kind",
+								Usage: "Schema to print: course (base.yaml) or task (tasks/N.yaml)",
+								Value: "task",
+							},
+							&cli.StringFlag{
+								Name:  "course_name",
+								Usage: "Save both schemas into <course_name>/.schema and add yaml-language-server modelines to course files",
+							},
+						},
+					},
+					{
+						Name:   "pack",
+						Usage:  "Validate the course and pack it into a versioned bundle with a manifest and checksum",
+						Action: lib.PackCourseCLI,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "course_name",
+								Usage:    "Course name",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "version",
+								Usage: "Course version. Defaults to `version` from base.yaml",
+							},
+							&cli.StringFlag{
+								Name:  "output",
+								Usage: "Bundle path. Defaults to <course_name>-<version>.tar.gz",
+							},
+						},
+					},
+					{
+						Name:      "publish",
+						Usage:     "Upload a course bundle to the platform",
+						ArgsUsage: "<bundle.tar.gz>",
+						Action:    lib.PublishCourseCLI,
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "draft",
+								Usage: "Upload as a draft course visible only to you",
+							},
+							&cli.StringFlag{
+								Name:  "api-url",
+								Usage: "Platform API URL to upload to",
+							},
+						},
+					},
+					{
+						Name:   "render",
+						Usage:  "Print a course file with includes and variables expanded",
+						Action: lib.RenderCourseCLI,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "course_name",
+								Usage:    "Course name",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "task",
+								Usage: "Task position in the course or taskID. Prints base.yaml if not set",
+							},
+							&cli.StringFlag{
+								Name:  "lang",
+								Usage: "Apply the translation to this language",
+							},
+						},
+					},
+					{
+						Name:   "reorder",
+						Usage:  "Renumber task files to close gaps, move or insert a task",
+						Action: lib.ReorderCourseCLI,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "course_name",
+								Usage:    "Course name",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "move",
+								Usage: "Task to move, by taskID or position",
+							},
+							&cli.StringFlag{
+								Name:  "insert",
+								Usage: "Task file to insert into the course",
+							},
+							&cli.IntFlag{
+								Name:  "to",
+								Usage: "New position of the moved or inserted task. Inserted tasks go last by default",
+							},
+							&cli.BoolFlag{
+								Name:  "dry-run",
+								Usage: "Only print the changes",
+							},
+						},
+					},
+					{
+						Name:      "diff",
+						Usage:     "Compare two versions of a course",
+						ArgsUsage: "<old-dir|bundle> <new-dir|bundle>",
+						Action:    lib.DiffCourseCLI,
+						Flags: []cli.Flag{
+							lib.OutputFlag(),
+							&cli.BoolFlag{
+								Name:  "fail-on-breaking",
+								Usage: "Exit with code 1 if changes lose or lock progress of students",
+							},
+						},
+					},
+					{
+						Name:   "stats",
+						Usage:  "Show course statistics and audit commands, media and links",
+						Action: lib.StatsCourseCLI,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "course_name",
+								Usage:    "Course name",
+								Required: true,
+							},
+							lib.OutputFlag(),
+						},
+					},
+					{
+						Name:   "i18n-status",
+						Usage:  "Show untranslated strings of course translations",
+						Action: lib.I18nStatusCLI,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "course_name",
+								Usage:    "Course name",
+								Required: true,
+							},
+							&cli.StringSliceFlag{
+								Name:  "lang",
+								Usage: "Languages to check. By default all languages with translation files",
+							},
+							lib.OutputFlag(),
+						},
+					},
+					{
+						Name:   "export",
+						Usage:  "Export the course to a static HTML site or a single Markdown file for review",
+						Action: lib.ExportCourseCLI,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "course_name",
+								Usage:    "Course name",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "format",
+								Usage: "Export format: html, markdown or markdown-src (the import-md format)",
+								Value: "html",
+							},
+							&cli.StringFlag{
+								Name:  "output",
+								Usage: "Output folder. Defaults to <course_name>-export",
+							},
+							&cli.BoolFlag{
+								Name:  "hide-answers",
+								Usage: "Do not mark correct quiz answers",
+							},
+						},
+					},
+					{
+						Name:      "import-md",
+						Usage:     "Create or update tasks from a Markdown document",
+						ArgsUsage: "<file.md>",
+						Action:    lib.ImportMarkdownCLI,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "course_name",
+								Usage:    "Course name",
+								Required: true,
+							},
+							&cli.BoolFlag{
+								Name:  "dry-run",
+								Usage: "Only print the task files that would be written",
+							},
+							&cli.BoolFlag{
+								Name:  "force",
+								Usage: "Overwrite task files that use $include or vars",
+							},
 						},
 					},
 					{
@@ -127,7 +475,7 @@ func main() {
 				Aliases: []string{"v"},
 				Action:  lib.Version,
 			},
-		},
+		}),
 	}
 
 	err := app.Run(os.Args)